@@ -0,0 +1,150 @@
+package parse
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Names of the third-party authentication providers supported by Parse.
+// Providers configured as custom auth adapters on the server may be used
+// by name as well.
+const (
+	AuthProviderAnonymous = "anonymous"
+	AuthProviderApple     = "apple"
+	AuthProviderFacebook  = "facebook"
+	AuthProviderGithub    = "github"
+	AuthProviderGoogle    = "google"
+	AuthProviderTwitter   = "twitter"
+)
+
+// Log in as the user linked to the account described by authData with the
+// given provider, signing up a new user if no user is linked to the account.
+//
+// authData may be one of the typed auth data structs (e.g. *AppleAuthData),
+// or any value that marshals to the JSON expected by the provider's adapter.
+//
+// Optionally provide a custom User type to use in place of parse.User. If u is not
+// nil, it will be populated with the user's attributes, and will be accessible
+// by calling session.User().
+func LoginWith(provider string, authData interface{}, u interface{}, opts ...RequestOption) (Session, error) {
+	var user interface{}
+
+	if u == nil {
+		user = &User{}
+	} else if err := validateUser(u); err != nil {
+		return nil, err
+	} else {
+		user = u
+	}
+
+	if provider == "" {
+		return nil, errors.New("provider must not be empty")
+	}
+
+	s := &sessionT{user: user}
+	r := &loginRequestT{authdata: map[string]interface{}{provider: authData}, headers: optionHeaders(opts)}
+	if b, err := defaultClient.doRequest(r); err != nil {
+		return nil, err
+	} else if st, err := handleLoginResponse(b, s.user); err != nil {
+		return nil, err
+	} else {
+		s.sessionToken = st
+	}
+
+	return s, nil
+}
+
+// Sign up and log in as a new anonymous user. An anonymous user may later
+// be converted to a regular user with Session.UpgradeAnonymousUser.
+//
+// Optionally provide a custom User type to use in place of parse.User.
+func LoginAnonymously(u interface{}, opts ...RequestOption) (Session, error) {
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	return LoginWith(AuthProviderAnonymous, &AnonymousAuthData{Id: id}, u, opts...)
+}
+
+// Link the account described by authData with the given provider to the
+// user u, using the master key
+func LinkWith(u interface{}, provider string, authData interface{}) error {
+	return linkWith(u, provider, authData, nil)
+}
+
+// Unlink the given provider from the user u, using the master key
+func Unlink(u interface{}, provider string) error {
+	return linkWith(u, provider, nil, nil)
+}
+
+func (s *sessionT) LinkWith(provider string, authData interface{}) error {
+	return linkWith(s.user, provider, authData, s)
+}
+
+func (s *sessionT) Unlink(provider string) error {
+	return linkWith(s.user, provider, nil, s)
+}
+
+func (s *sessionT) UpgradeAnonymousUser(username, password string) error {
+	if username == "" || password == "" {
+		return errors.New("username and password must not be empty")
+	}
+
+	up, err := s.NewUpdate(s.user)
+	if err != nil {
+		return err
+	}
+	up.Set("username", username)
+	up.Set("password", password)
+
+	// Parse SDKs drop the anonymous auth data when upgrading, so the user
+	// is no longer treated as anonymous
+	up.Set("authData", map[string]interface{}{AuthProviderAnonymous: nil})
+	return up.Execute()
+}
+
+// Sets the auth data for provider on the user u. Passing nil authData
+// unlinks the provider. Uses the master key if currentSession is nil.
+func linkWith(u interface{}, provider string, authData interface{}, currentSession *sessionT) error {
+	if err := validateUser(u); err != nil {
+		return err
+	}
+
+	if provider == "" {
+		return errors.New("provider must not be empty")
+	}
+
+	if f := reflect.Indirect(reflect.ValueOf(u)).FieldByName("Id"); !f.IsValid() || f.String() == "" {
+		return errors.New("user Id field must not be empty")
+	}
+
+	var up Update
+	var err error
+	if currentSession != nil {
+		up, err = currentSession.NewUpdate(u)
+	} else {
+		up, err = NewUpdate(u)
+		if err == nil {
+			up.UseMasterKey()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	up.Set("authData", map[string]interface{}{provider: authData})
+	return up.Execute()
+}
+
+// Returns a random (version 4) UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
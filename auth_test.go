@@ -0,0 +1,183 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestLoginWith(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/1/users" {
+			t.Errorf("unexpected request: %s %s\n", r.Method, r.URL.Path)
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		expected := `{"authData":{"apple":{"id":"a1","token":"t1"}}}`
+		if string(b) != expected {
+			t.Errorf("unexpected body. Got:\n%s\nexpected:\n%s\n", b, expected)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"objectId":"u1","username":"generated","sessionToken":"r:abcd"}`)
+	})
+	defer teardownTestServer()
+
+	s, err := LoginWith(AuthProviderApple, &AppleAuthData{Id: "a1", Token: "t1"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error logging in: %v\n", err)
+	}
+
+	if st := s.(*sessionT).sessionToken; st != "r:abcd" {
+		t.Errorf("unexpected session token. Got %s\n", st)
+	}
+	if u := s.User().(*User); u.Id != "u1" {
+		t.Errorf("user was not populated: %+v\n", u)
+	}
+}
+
+func TestLoginAnonymously(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]map[string]AnonymousAuthData{}
+		b, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Errorf("unexpected body: %s\n", b)
+		}
+
+		id := body["authData"]["anonymous"].Id
+		if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+			t.Errorf("anonymous id was not a UUID. Got %s\n", id)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"objectId":"u1","sessionToken":"r:anon"}`)
+	})
+	defer teardownTestServer()
+
+	if _, err := LoginAnonymously(nil); err != nil {
+		t.Errorf("unexpected error logging in: %v\n", err)
+	}
+}
+
+func TestLinkAndUnlink(t *testing.T) {
+	bodies := []map[string]interface{}{}
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/1/users/u1" {
+			t.Errorf("unexpected request: %s %s\n", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(SessionTokenHeader); h != "r:abcd" {
+			t.Errorf("request did not use session token. Got [%s]\n", h)
+		}
+
+		body := map[string]interface{}{}
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+		bodies = append(bodies, body)
+		fmt.Fprintf(w, `{"updatedAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	s := &sessionT{user: &User{Base: Base{Id: "u1"}}, sessionToken: "r:abcd"}
+	if err := s.LinkWith(AuthProviderGithub, &GithubAuthData{Id: "g1", AccessToken: "tok"}); err != nil {
+		t.Errorf("unexpected error linking: %v\n", err)
+	}
+	if err := s.Unlink(AuthProviderGithub); err != nil {
+		t.Errorf("unexpected error unlinking: %v\n", err)
+	}
+
+	expected := []map[string]interface{}{
+		{"authData": map[string]interface{}{"github": map[string]interface{}{"id": "g1", "access_token": "tok"}}},
+		{"authData": map[string]interface{}{"github": nil}},
+	}
+	if !reflect.DeepEqual(bodies, expected) {
+		t.Errorf("unexpected bodies. Got:\n%v\nexpected:\n%v\n", bodies, expected)
+	}
+}
+
+type LinkedUser struct {
+	User
+	AuthData *AuthData
+}
+
+func TestLinkAndUnlinkUpdateAuthData(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"updatedAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	u := &LinkedUser{
+		User:     User{Base: Base{Id: "u1"}},
+		AuthData: &AuthData{Apple: &AppleAuthData{Id: "a1"}},
+	}
+	if err := LinkWith(u, AuthProviderGithub, &GithubAuthData{Id: "g1", AccessToken: "tok"}); err != nil {
+		t.Fatalf("unexpected error linking: %v\n", err)
+	}
+	if u.AuthData.Github == nil || u.AuthData.Github.Id != "g1" || u.AuthData.Apple == nil {
+		t.Errorf("github auth data was not merged into the user: %+v\n", u.AuthData)
+	}
+
+	if err := Unlink(u, AuthProviderGithub); err != nil {
+		t.Fatalf("unexpected error unlinking: %v\n", err)
+	}
+	if u.AuthData.Github != nil {
+		t.Errorf("github auth data was not cleared: %+v\n", u.AuthData)
+	}
+	if u.AuthData.Apple == nil {
+		t.Errorf("apple auth data should not be cleared")
+	}
+
+	eu := &User{Base: Base{Id: "u2", Extra: map[string]interface{}{
+		"AuthData": map[string]interface{}{"github": map[string]interface{}{"id": "g1"}},
+	}}}
+	if err := Unlink(eu, AuthProviderGithub); err != nil {
+		t.Fatalf("unexpected error unlinking: %v\n", err)
+	}
+	if ad, _ := eu.Extra["AuthData"].(map[string]interface{}); ad == nil {
+		t.Errorf("auth data missing from Extra: %+v\n", eu.Extra)
+	} else if _, ok := ad["github"]; ok {
+		t.Errorf("github auth data was not cleared from Extra: %+v\n", eu.Extra)
+	}
+}
+
+func TestLinkWithRequiresId(t *testing.T) {
+	if err := LinkWith(&User{}, AuthProviderGoogle, &GoogleAuthData{Id: "g1"}); err == nil {
+		t.Errorf("expected error linking user with no Id")
+	}
+}
+
+func TestUpgradeAnonymousUser(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+		expected := map[string]interface{}{
+			"username": "kylemcc",
+			"password": "secret",
+			"authData": map[string]interface{}{"anonymous": nil},
+		}
+		if !reflect.DeepEqual(body, expected) {
+			t.Errorf("unexpected body: %s\n", b)
+		}
+		fmt.Fprintf(w, `{"updatedAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	u := &LinkedUser{User: User{Base: Base{Id: "u1"}}, AuthData: &AuthData{Anonymous: &AnonymousAuthData{Id: "anon"}}}
+	s := &sessionT{user: u, sessionToken: "r:anon"}
+	if err := s.UpgradeAnonymousUser("kylemcc", "secret"); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if u.Username != "kylemcc" {
+		t.Errorf("username was not set on user: %+v\n", u)
+	}
+	if _, ok := u.Extra["Password"]; ok {
+		t.Errorf("password should not be kept on the user: %+v\n", u.Extra)
+	}
+	if u.AuthData.Anonymous != nil {
+		t.Errorf("anonymous auth data was not cleared: %+v\n", u.AuthData)
+	}
+}
@@ -0,0 +1,398 @@
+package parse
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A structCodec holds the field plans for a struct type, compiled once and
+// cached, so that populating and encoding values of that type doesn't
+// require walking its fields with reflection for every object.
+type structCodec struct {
+	// Fields keyed by every name that may refer to them in a Parse object -
+	// the parse tag name, the json tag name, the field name, and the field
+	// name with its first letter lower-cased
+	fields map[string]*fieldPlan
+
+	// Index of the Extra field, or nil if the type has no such field
+	extra []int
+
+	// Index of the embedded ChangeTracker, or nil if the type doesn't
+	// embed one
+	tracker []int
+
+	// Fields sent when creating or saving an object, in declaration order
+	encodeFields []encodeFieldT
+
+	// Fields sent when a value of the type is nested inside another object.
+	// These are named as encoding/json would name them, unless they have a
+	// parse tag.
+	nestedFields []encodeFieldT
+
+	// Fields tagged parse:"-" that are populated from Parse objects but
+	// never sent, such as CreatedAt and User.EmailVerified. Doesn't include
+	// the Extra field.
+	readOnlyFields []encodeFieldT
+}
+
+type fieldPlan struct {
+	name  string
+	index []int
+
+	// The name of the field in Parse objects, and the field's type
+	wire string
+	typ  reflect.Type
+
+	// The name of the field when the struct is nested inside another object
+	nested string
+
+	// Whether values of the field's type are represented the same way by
+	// Parse and encoding/json, so that they may be decoded directly
+	direct bool
+}
+
+type encodeFieldT struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// Cache of compiled codecs, keyed by struct type. Values are of type
+// *structCodec, and are never modified once stored.
+var codecCache sync.Map
+
+var baseType = reflect.TypeOf(Base{})
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Returns the codec for the struct type t, compiling it on first use
+func codecFor(t reflect.Type) *structCodec {
+	if c, ok := codecCache.Load(t); ok {
+		return c.(*structCodec)
+	}
+
+	c, _ := codecCache.LoadOrStore(t, compileCodec(t))
+	return c.(*structCodec)
+}
+
+func compileCodec(t reflect.Type) *structCodec {
+	c := &structCodec{
+		fields: map[string]*fieldPlan{},
+	}
+
+	// Any field reachable by name - including promoted fields of embedded
+	// structs - is populated by a key matching its name once the key's first
+	// letter is upper-cased
+	for _, f := range reflect.VisibleFields(t) {
+		if firstToUpper(f.Name) != f.Name {
+			continue
+		}
+
+		p := &fieldPlan{name: f.Name, index: f.Index, wire: firstToLower(f.Name), nested: f.Name, typ: f.Type, direct: isPlainType(f.Type)}
+		c.fields[f.Name] = p
+		c.fields[firstToLower(f.Name)] = p
+	}
+
+	if p, ok := c.fields["Extra"]; ok && p.name == "Extra" {
+		c.extra = p.index
+	}
+
+	for _, f := range reflect.VisibleFields(t) {
+		if f.Anonymous && f.Type == changeTrackerType {
+			c.tracker = f.Index
+			break
+		}
+	}
+
+	// Names provided by parse tags take precedence over json tags, which
+	// take precedence over field names
+	for _, f := range getFields(t) {
+		name, opts := parseTag(f.Tag.Get("parse"))
+		sf, ok := t.FieldByName(f.Name)
+		if !ok {
+			continue
+		}
+
+		jsonName, _ := parseTag(f.Tag.Get("json"))
+		if jsonName != "" && jsonName != "-" {
+			if fp, ok := c.fields[f.Name]; ok && reflect.DeepEqual(fp.index, sf.Index) {
+				fp.nested = jsonName
+				if _, ok := c.fields[jsonName]; !ok {
+					c.fields[jsonName] = fp
+				}
+			}
+		}
+
+		if name != "" && name != "-" {
+			p := &fieldPlan{name: f.Name, index: sf.Index, wire: name, nested: name, typ: sf.Type, direct: isPlainType(sf.Type)}
+			c.fields[name] = p
+			if fp, ok := c.fields[f.Name]; ok && reflect.DeepEqual(fp.index, sf.Index) {
+				c.fields[f.Name] = p
+				c.fields[firstToLower(f.Name)] = p
+			}
+		}
+
+		if name == "-" && !reflect.DeepEqual(sf.Index, c.extra) {
+			c.readOnlyFields = append(c.readOnlyFields, encodeFieldT{
+				name:      firstToLower(f.Name),
+				index:     sf.Index,
+				omitEmpty: true,
+			})
+		}
+
+		if name == "-" || name == "objectId" || f.Name == "Id" || f.Type == baseType {
+			continue
+		}
+
+		nested := name
+		if nested == "" {
+			nested = f.Name
+			if jsonName == "-" {
+				continue
+			} else if jsonName != "" {
+				nested = jsonName
+			}
+		}
+		c.nestedFields = append(c.nestedFields, encodeFieldT{
+			name:      nested,
+			index:     sf.Index,
+			omitEmpty: opts == "omitempty" || strings.Contains(f.Tag.Get("json"), ",omitempty"),
+		})
+
+		if name == "" {
+			name = firstToLower(f.Name)
+		}
+		c.encodeFields = append(c.encodeFields, encodeFieldT{
+			name:      name,
+			index:     sf.Index,
+			omitEmpty: opts == "omitempty",
+		})
+	}
+
+	return c
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Reports whether t is made up only of booleans, numbers, strings, and
+// slices and string-keyed maps of them, with no custom unmarshaling, so that
+// encoding/json decodes it exactly as populateValue would
+func isPlainType(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8 && isPlainType(t.Elem())
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && isPlainType(t.Elem())
+	}
+	return false
+}
+
+// Returns the field referred to by the key k in a Parse object, or nil if
+// the type has no matching field
+func (c *structCodec) field(k string) *fieldPlan {
+	return c.fields[k]
+}
+
+// Returns the representation of the struct value v to be sent when creating
+// or saving an object
+func (c *structCodec) encode(v reflect.Value) map[string]interface{} {
+	return encodeFields(v, c.encodeFields)
+}
+
+// Returns the representation of the struct value v to be sent when it is
+// nested inside another object
+func (c *structCodec) encodeNested(v reflect.Value) map[string]interface{} {
+	return encodeFields(v, c.nestedFields)
+}
+
+func encodeFields(v reflect.Value, fields []encodeFieldT) map[string]interface{} {
+	payload := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		fv := v.FieldByIndex(f.index)
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		if canBeNil(fv) && fv.IsNil() {
+			payload[f.name] = nil
+		} else {
+			payload[f.name] = encodeForRequest(fv.Interface())
+		}
+	}
+	return payload
+}
+
+// Returns the full representation of the object v, including the fields
+// managed by Parse, the fields that are never sent, and the contents of
+// Extra, in the form in which Parse returns objects. The result may be
+// decoded with populateValue.
+func encodeObject(v interface{}) map[string]interface{} {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	c := codecFor(rv.Type())
+
+	obj := map[string]interface{}{}
+	if c.extra != nil {
+		if extra, ok := rv.FieldByIndex(c.extra).Interface().(map[string]interface{}); ok {
+			for k, ev := range extra {
+				obj[k] = encodeForRequest(ev)
+			}
+		}
+	}
+
+	for k, fv := range c.encode(rv) {
+		obj[k] = fv
+	}
+
+	for _, f := range c.readOnlyFields {
+		fv := rv.FieldByIndex(f.index)
+		if isEmptyValue(fv) || (fv.Type() == timeType && fv.Interface().(time.Time).IsZero()) {
+			continue
+		}
+		obj[f.name] = encodeForRequest(fv.Interface())
+	}
+
+	if f := rv.FieldByName("Id"); f.IsValid() && f.String() != "" {
+		obj["objectId"] = f.String()
+	}
+	return obj
+}
+
+// Returns the struct type underlying v - a struct, a pointer to one, or a
+// slice of either - or nil if there isn't one
+func structType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// Returns the key k - a field name, or a dot-separated path to a field of
+// a nested struct - with each element replaced by the name Parse uses for
+// that field in objects of type t. Elements past the first that doesn't
+// refer to a struct field, such as keys of a map, are left as is.
+func wireKey(t reflect.Type, k string) string {
+	if t == nil {
+		return k
+	}
+
+	path := strings.Split(k, ".")
+	for i, p := range path {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			break
+		}
+
+		fp := codecFor(t).field(p)
+		if fp == nil {
+			break
+		}
+		if i == 0 {
+			path[i] = fp.wire
+		} else {
+			path[i] = fp.nested
+		}
+		t = fp.typ
+	}
+	return strings.Join(path, ".")
+}
+
+// Returns the value found by following path from v. Each element of path
+// names a field of a struct, or a key of a map. Nil pointers and missing map
+// keys along the way yield nil. Reports false if an element doesn't name a
+// field of a struct.
+func valueAtPath(v reflect.Value, path []string) (interface{}, bool) {
+	for _, p := range path {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, true
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			fp := codecFor(v.Type()).field(p)
+			if fp == nil {
+				return nil, false
+			}
+			v = v.FieldByIndex(fp.index)
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			v = v.MapIndex(reflect.ValueOf(p).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return nil, true
+			}
+		default:
+			return nil, false
+		}
+	}
+	return v.Interface(), true
+}
+
+// Returns a copy of the query constraints m with their keys translated by
+// wireKey, including those of constraints combined with $or and $and
+func wireConstraints(t reflect.Type, m map[string]interface{}) map[string]interface{} {
+	if t == nil {
+		return m
+	}
+
+	wm := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		switch k {
+		case "$or", "$and":
+			switch cs := v.(type) {
+			case []map[string]interface{}:
+				wcs := make([]map[string]interface{}, len(cs))
+				for i, c := range cs {
+					wcs[i] = wireConstraints(t, c)
+				}
+				v = wcs
+			case []interface{}:
+				wcs := make([]interface{}, len(cs))
+				for i, c := range cs {
+					if cm, ok := c.(map[string]interface{}); ok {
+						wcs[i] = wireConstraints(t, cm)
+					} else {
+						wcs[i] = c
+					}
+				}
+				v = wcs
+			}
+			wm[k] = v
+		default:
+			if strings.HasPrefix(k, "$") {
+				wm[k] = v
+			} else {
+				wm[wireKey(t, k)] = v
+			}
+		}
+	}
+	return wm
+}
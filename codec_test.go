@@ -0,0 +1,415 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type CodecTest struct {
+	Base
+	Name     string `parse:"name"`
+	Count    int
+	Score    float64 `parse:",omitempty"`
+	Tags     []string
+	Location GeoPoint
+	Owner    *User
+	Seen     time.Time
+	Skipped  string `parse:"-"`
+	private  string
+}
+
+func TestCodecFieldLookup(t *testing.T) {
+	c := codecFor(reflect.TypeOf(CodecTest{}))
+
+	cases := []struct {
+		key      string
+		expected string
+	}{
+		{"objectId", "Id"},
+		{"Id", "Id"},
+		{"createdAt", "CreatedAt"},
+		{"name", "Name"},
+		{"Name", "Name"},
+		{"count", "Count"},
+		{"skipped", "Skipped"},
+		{"ACL", "ACL"},
+		{"missing", ""},
+	}
+
+	for _, tc := range cases {
+		var actual string
+		if f := c.field(tc.key); f != nil {
+			actual = f.name
+		}
+		if actual != tc.expected {
+			t.Errorf("wrong field for key [%s]. Got [%s] expected [%s]\n", tc.key, actual, tc.expected)
+		}
+	}
+
+	if f := c.field("private"); f != nil {
+		t.Errorf("unexported field should not be found")
+	}
+
+	if c.extra == nil {
+		t.Errorf("codec did not find Extra field")
+	}
+}
+
+func TestCodecEncode(t *testing.T) {
+	v := CodecTest{
+		Base:    Base{Id: "abc"},
+		Name:    "name",
+		Count:   3,
+		Skipped: "skipped",
+		Seen:    time.Date(2014, 12, 20, 18, 31, 19, 123000000, time.UTC),
+		Owner:   &User{Base: Base{Id: "xyz"}},
+	}
+
+	m := codecFor(reflect.TypeOf(v)).encode(reflect.ValueOf(v))
+
+	b, _ := json.Marshal(m)
+	actual := map[string]interface{}{}
+	_ = json.Unmarshal(b, &actual)
+
+	eb, _ := json.Marshal(map[string]interface{}{
+		"name":     "name",
+		"count":    3,
+		"tags":     nil,
+		"location": GeoPoint{},
+		"owner":    Pointer{Id: "xyz", ClassName: "_User"},
+		"seen":     Date(v.Seen),
+	})
+	expected := map[string]interface{}{}
+	_ = json.Unmarshal(eb, &expected)
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("encoded struct different from expected. expected:\n%s\n\ngot:\n%s\n", eb, b)
+	}
+}
+
+func benchmarkPage(n int) []byte {
+	results := make([]map[string]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		results = append(results, map[string]interface{}{
+			"objectId":  fmt.Sprintf("obj%d", i),
+			"createdAt": "2014-12-19T22:22:22.123Z",
+			"updatedAt": "2014-12-19T22:22:22.123Z",
+			"name":      fmt.Sprintf("name %d", i),
+			"count":     i,
+			"score":     float64(i) / 3,
+			"tags":      []string{"a", "b", "c"},
+			"location":  map[string]interface{}{"__type": "GeoPoint", "latitude": 41.9, "longitude": -87.6},
+			"owner":     map[string]interface{}{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+			"seen":      map[string]interface{}{"__type": "Date", "iso": "2014-12-19T22:22:22.123Z"},
+			"other":     "extra",
+		})
+	}
+	b, _ := json.Marshal(map[string]interface{}{"results": results})
+	return b
+}
+
+func BenchmarkHandleResponse1000(b *testing.B) {
+	body := benchmarkPage(1000)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		dst := make([]CodecTest, 0)
+		if err := handleResponse(body, &dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreateBody1000(b *testing.B) {
+	vs := make([]CodecTest, 1000)
+	for i := range vs {
+		vs[i] = CodecTest{Name: fmt.Sprintf("name %d", i), Count: i, Tags: []string{"a", "b"}, Owner: &User{Base: Base{Id: "u1"}}, Seen: time.Now()}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := range vs {
+			cr := createT{v: &vs[j]}
+			if _, err := cr.body(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkHandleResultStream1000(b *testing.B) {
+	body := benchmarkPage(1000)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		dst := make([]CodecTest, 0)
+		if _, err := handleResultStream(bytes.NewReader(body), &dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestResultStreamMatchesHandleResponse(t *testing.T) {
+	body := benchmarkPage(3)
+
+	buffered := []CodecTest{}
+	if err := handleResponse(body, &buffered); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	streamed := []*CodecTest{}
+	if _, err := handleResultStream(bytes.NewReader(body), &streamed); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	if len(streamed) != len(buffered) {
+		t.Fatalf("expected %d results. Got %d\n", len(buffered), len(streamed))
+	}
+	for i := range buffered {
+		if !reflect.DeepEqual(*streamed[i], buffered[i]) {
+			t.Errorf("streamed result differs. Got:\n%+v\nexpected:\n%+v\n", *streamed[i], buffered[i])
+		}
+	}
+}
+
+type NotificationSettings struct {
+	Email bool
+	Push  bool `parse:"mobilePush"`
+}
+
+type AccountSettings struct {
+	Notifications *NotificationSettings `parse:"notif"`
+	Limits        map[string]int
+}
+
+type Account struct {
+	Base
+	Settings AccountSettings
+}
+
+func TestWireKey(t *testing.T) {
+	at := reflect.TypeOf(Account{})
+	for k, expected := range map[string]string{
+		"Settings.Notifications.Email":  "settings.notif.Email",
+		"settings.notif.Push":           "settings.notif.mobilePush",
+		"settings.limits.Daily":         "settings.Limits.Daily",
+		"Settings.unknown.Email":        "settings.unknown.Email",
+		"Id":                            "objectId",
+		"Settings.Notifications.Push.x": "settings.notif.mobilePush.x",
+	} {
+		if k := wireKey(at, k); k != expected {
+			t.Errorf("unexpected key. Got [%s] expected [%s]\n", k, expected)
+		}
+	}
+}
+
+func TestNestedKeysInRequests(t *testing.T) {
+	a := &Account{Base: Base{Id: "a1"}}
+	q, _ := NewQuery(a)
+	q.EqualTo("Settings.Notifications.Email", true).OrderBy("-Settings.Limits.daily")
+	q.Include("Settings.Notifications").Keys("Settings.Limits")
+	qs, err := q.(*queryT).payload()
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	v, _ := url.ParseQuery(qs)
+	if w := v.Get("where"); w != `{"settings.notif.Email":true}` {
+		t.Errorf("unexpected where. Got %s\n", w)
+	}
+	if o := v.Get("order"); o != "-settings.Limits.daily" {
+		t.Errorf("unexpected order. Got %s\n", o)
+	}
+	if i := v.Get("include"); i != "settings.notif" {
+		t.Errorf("unexpected include. Got %s\n", i)
+	}
+	if k := v.Get("keys"); k != "settings.Limits" {
+		t.Errorf("unexpected keys. Got %s\n", k)
+	}
+
+	up, _ := NewUpdate(a)
+	up.Set("Settings.Notifications.Push", true).Increment("settings.limits.daily", 1)
+	body, _ := up.(*updateT).body()
+	expected := `{"settings.Limits.daily":{"__op":"Increment","amount":1},"settings.notif.mobilePush":true}`
+	if body != expected {
+		t.Errorf("unexpected body. Got:\n%s\nexpected:\n%s\n", body, expected)
+	}
+
+	cond, _ := NewQuery(a)
+	cond.EqualTo("Settings.Notifications.Push", false)
+	up.If(cond)
+	e, err := up.(*updateT).endpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	eu, _ := url.Parse(e)
+	if w := eu.Query().Get("where"); w != `{"settings.notif.mobilePush":false}` {
+		t.Errorf("unexpected update where. Got %s\n", w)
+	}
+}
+
+func TestNestedKeysAppliedLocally(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"updatedAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	a := &Account{Base: Base{Id: "a1"}}
+	up, _ := NewUpdate(a)
+	up.Set("settings.notif.mobilePush", true).Increment("Settings.Limits.daily", 2)
+	if err := up.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	if a.Settings.Notifications == nil || !a.Settings.Notifications.Push {
+		t.Errorf("nested field was not set: %+v\n", a.Settings)
+	}
+	if a.Settings.Limits["daily"] != 2 {
+		t.Errorf("nested map entry was not incremented: %+v\n", a.Settings)
+	}
+}
+
+func TestNestedStructsEncodedWithWireNames(t *testing.T) {
+	a := &Account{Settings: AccountSettings{Notifications: &NotificationSettings{Email: true}}}
+	body, err := (&createT{v: a}).body()
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	expected := `{"settings":{"Limits":null,"notif":{"Email":true,"mobilePush":false}}}`
+	if body != expected {
+		t.Errorf("unexpected create body. Got:\n%s\nexpected:\n%s\n", body, expected)
+	}
+
+	decoded := Account{}
+	data := map[string]interface{}{}
+	json.Unmarshal([]byte(body), &data)
+	if err := populateValue(&decoded, data); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if decoded.Settings.Notifications == nil || !decoded.Settings.Notifications.Email {
+		t.Errorf("encoded object did not decode: %+v\n", decoded.Settings)
+	}
+
+	up, _ := NewUpdate(a)
+	up.Set("Settings", AccountSettings{Limits: map[string]int{"daily": 1}})
+	body, _ = up.(*updateT).body()
+	expected = `{"settings":{"Limits":{"daily":1},"notif":null}}`
+	if body != expected {
+		t.Errorf("unexpected update body. Got:\n%s\nexpected:\n%s\n", body, expected)
+	}
+}
+
+type DeviceInfo struct {
+	Addr         netip.Addr
+	EmailEnabled bool   `json:"email_enabled"`
+	Model        string `json:"model,omitempty"`
+	Secret       string `json:"-"`
+	Name         string `parse:"deviceName" json:"name"`
+}
+
+type Device struct {
+	Base
+	Info DeviceInfo
+}
+
+func TestNestedStructsHonorJSONTags(t *testing.T) {
+	d := &Device{Info: DeviceInfo{
+		Addr:         netip.MustParseAddr("10.0.0.1"),
+		EmailEnabled: true,
+		Secret:       "s",
+		Name:         "phone",
+	}}
+	body, err := (&createT{v: d}).body()
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	expected := `{"info":{"Addr":"10.0.0.1","deviceName":"phone","email_enabled":true}}`
+	if body != expected {
+		t.Errorf("unexpected create body. Got:\n%s\nexpected:\n%s\n", body, expected)
+	}
+
+	dt := reflect.TypeOf(Device{})
+	if k := wireKey(dt, "Info.EmailEnabled"); k != "info.email_enabled" {
+		t.Errorf("unexpected key. Got [%s]\n", k)
+	}
+	if k := wireKey(dt, "info.email_enabled"); k != "info.email_enabled" {
+		t.Errorf("unexpected key. Got [%s]\n", k)
+	}
+
+	decoded := Device{}
+	data := map[string]interface{}{}
+	json.Unmarshal([]byte(body), &data)
+	if err := populateValue(&decoded, data); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if !decoded.Info.EmailEnabled || decoded.Info.Name != "phone" || decoded.Info.Addr != d.Info.Addr {
+		t.Errorf("encoded object did not decode: %+v\n", decoded.Info)
+	}
+}
+
+type PostStats struct {
+	Views int
+}
+
+type Post struct {
+	Base
+	Stats *PostStats
+}
+
+func TestPageOnNestedField(t *testing.T) {
+	numRequests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		r.ParseForm()
+		if o := r.Form.Get("order"); o != "-stats.Views,objectId" {
+			t.Errorf("unexpected order. Got [%s]\n", o)
+		}
+
+		where := map[string]interface{}{}
+		json.Unmarshal([]byte(r.Form.Get("where")), &where)
+		switch numRequests {
+		case 1:
+			fmt.Fprintf(w, `{"results":[{"objectId":"a","stats":{"Views":10}},{"objectId":"b","stats":{"Views":7}}]}`)
+		default:
+			expected := []interface{}{
+				map[string]interface{}{"stats.Views": map[string]interface{}{"$lt": 7.0}},
+				map[string]interface{}{"stats.Views": 7.0, "objectId": map[string]interface{}{"$gt": "b"}},
+			}
+			if !reflect.DeepEqual(where["$or"], expected) {
+				t.Errorf("unexpected page boundary. Got [%v] expected [%v]\n", where["$or"], expected)
+			}
+			fmt.Fprintf(w, `{"results":[]}`)
+		}
+	})
+	defer teardownTestServer()
+
+	ps := make([]Post, 0)
+	q, _ := NewQuery(&ps)
+	q.OrderBy("-stats.views").Limit(2)
+
+	token, err := q.Page("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if token == "" {
+		t.Fatalf("expected a page token\n")
+	}
+	if _, err := q.Page(token); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+	if numRequests != 2 {
+		t.Errorf("expected 2 requests. Got %d\n", numRequests)
+	}
+}
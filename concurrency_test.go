@@ -0,0 +1,161 @@
+package parse
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// These tests exercise the library's shared state - the reflection caches and
+// registered types - from many goroutines at once. Run them with the race
+// detector enabled (go test -race) to catch unsynchronized access.
+
+type ConcurrentA struct {
+	Base
+	Name  string `parse:"name"`
+	Count int
+}
+
+type ConcurrentB struct {
+	Base
+	Title string
+	Owner *User
+}
+
+func setupConcurrentTestServer(requests *int64) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(requests, 1)
+		r.ParseForm()
+
+		switch {
+		case r.Method == "POST":
+			fmt.Fprintf(w, `{"objectId":"new","createdAt":"2014-12-19T22:22:22.123Z"}`)
+		case strings.Contains(r.Form.Get("where"), "objectId"):
+			fmt.Fprintf(w, `{"results":[]}`)
+		default:
+			results := make([]string, 0, 10)
+			for i := 0; i < 10; i++ {
+				results = append(results, fmt.Sprintf(`{"objectId":"%d","createdAt":"2014-12-19T22:22:22.123Z","name":"n%d","count":%d,"title":"t","owner":{"__type":"Pointer","className":"_User","objectId":"u%d"}}`, i, i, i, i))
+			}
+			fmt.Fprintf(w, `{"results":[%s]}`, strings.Join(results, ","))
+		}
+	})
+}
+
+func TestConcurrentFind(t *testing.T) {
+	var requests int64
+	setupConcurrentTestServer(&requests)
+	defer teardownTestServer()
+
+	q, _ := NewQuery(&[]ConcurrentA{})
+	q.EqualTo("name", "n1")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Exercise both a shared query and per-goroutine queries of
+			// different types
+			as := make([]ConcurrentA, 0)
+			sq, _ := NewQuery(&as)
+			if err := sq.Find(); err != nil {
+				t.Errorf("unexpected error running Find: %v\n", err)
+			} else if len(as) != 10 || as[3].Count != 3 {
+				t.Errorf("Find did not populate results. Got: %v\n", as)
+			}
+
+			bs := make([]*ConcurrentB, 0)
+			bq, _ := NewQuery(&bs)
+			if err := bq.Find(); err != nil {
+				t.Errorf("unexpected error running Find: %v\n", err)
+			} else if len(bs) != 10 || bs[3].Owner.Id != "u3" {
+				t.Errorf("Find did not populate results. Got: %v\n", bs)
+			}
+
+			if _, err := q.Clone().Count(); err != nil {
+				t.Errorf("unexpected error running Count: %v\n", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentCreate(t *testing.T) {
+	var requests int64
+	setupConcurrentTestServer(&requests)
+	defer teardownTestServer()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			a := ConcurrentA{Name: fmt.Sprintf("n%d", i), Count: i}
+			if err := Create(&a, false); err != nil {
+				t.Errorf("unexpected error running Create: %v\n", err)
+			} else if a.Id != "new" {
+				t.Errorf("Create did not populate Id. Got: %v\n", a.Id)
+			}
+
+			if err := RegisterType(&ConcurrentB{}); err != nil {
+				t.Errorf("unexpected error registering type: %v\n", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if requests != 20 {
+		t.Errorf("Create did not execute the expected number of requests. Expected 20, got: %d\n", requests)
+	}
+}
+
+func TestConcurrentEach(t *testing.T) {
+	var requests int64
+	setupConcurrentTestServer(&requests)
+	defer teardownTestServer()
+
+	q, _ := NewQuery(&ConcurrentA{})
+	q.SetBatchSize(10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			rc := make(chan *ConcurrentA)
+			it, err := q.Each(rc)
+			if err != nil {
+				t.Errorf("unexpected error running Each: %v\n", err)
+				return
+			}
+
+			n := 0
+			for range rc {
+				n++
+				if i%2 == 0 && n == 5 {
+					it.Cancel()
+				}
+			}
+
+			if err := <-it.Done(); err != nil {
+				t.Errorf("unexpected error from Each: %v\n", err)
+			}
+
+			if it.Error() != nil {
+				t.Errorf("unexpected error from Each: %v\n", it.Error())
+			}
+
+			if i%2 != 0 && n != 10 {
+				t.Errorf("Wrong number of results received. Expected 10, got: %d\n", n)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -3,6 +3,7 @@ package parse
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
 	"net/url"
 	"reflect"
 )
@@ -11,6 +12,7 @@ type createT struct {
 	v                  interface{}
 	shouldUseMasterKey bool
 	currentSession     *sessionT
+	headers            http.Header
 
 	isUser   bool
 	username string
@@ -39,35 +41,9 @@ func (c *createT) body() (string, error) {
 		payload["password"] = c.password
 	}
 
-	rv := reflect.ValueOf(c.v)
-	rvi := reflect.Indirect(rv)
-	rt := rvi.Type()
-	fields := getFields(rt)
-
-	for _, f := range fields {
-		var name string
-		var fv reflect.Value
-
-		if n, o := parseTag(f.Tag.Get("parse")); n == "-" || n == "objectId" || f.Name == "Id" || f.Type == reflect.TypeOf(Base{}) {
-			continue
-		} else if fv = rvi.FieldByName(f.Name); !fv.IsValid() || o == "omitempty" && isEmptyValue(fv) {
-			continue
-		} else {
-			name = n
-		}
-
-		var fname string
-		if name != "" {
-			fname = name
-		} else {
-			fname = firstToLower(f.Name)
-		}
-
-		if canBeNil(fv) && fv.IsNil() {
-			payload[fname] = nil
-		} else {
-			payload[fname] = encodeForRequest(fv.Interface())
-		}
+	rvi := reflect.Indirect(reflect.ValueOf(c.v))
+	for k, v := range codecFor(rvi.Type()).encode(rvi) {
+		payload[k] = v
 	}
 
 	b, err := json.Marshal(payload)
@@ -90,21 +66,26 @@ func (c *createT) contentType() string {
 	return "application/json"
 }
 
+func (c *createT) requestHeaders() http.Header {
+	return c.headers
+}
+
 // Save a new instance of the type pointed to by v to the Parse database. If
 // useMasteKey=true, the Master Key will be used for the creation request. On a
 // successful request, the CreatedAt field will be set on v.
 //
 // Note: v should be a pointer to a struct whose name represents a Parse class,
 // or that implements the ClassName method
-func Create(v interface{}, useMasterKey bool) error {
-	return create(v, useMasterKey, nil)
+func Create(v interface{}, useMasterKey bool, opts ...RequestOption) error {
+	return create(v, useMasterKey, nil, opts...)
 }
 
-func Signup(username string, password string, user interface{}) error {
+func Signup(username string, password string, user interface{}, opts ...RequestOption) error {
 	cr := &createT{
 		v:                  user,
 		shouldUseMasterKey: false,
 		currentSession:     nil,
+		headers:            optionHeaders(opts),
 		isUser:             true,
 		username:           username,
 		password:           password,
@@ -116,7 +97,7 @@ func Signup(username string, password string, user interface{}) error {
 	}
 }
 
-func create(v interface{}, useMasterKey bool, currentSession *sessionT) error {
+func create(v interface{}, useMasterKey bool, currentSession *sessionT, opts ...RequestOption) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return errors.New("v must be a non-nil pointer")
@@ -126,6 +107,7 @@ func create(v interface{}, useMasterKey bool, currentSession *sessionT) error {
 		v:                  v,
 		shouldUseMasterKey: useMasterKey,
 		currentSession:     currentSession,
+		headers:            optionHeaders(opts),
 	}
 	if b, err := defaultClient.doRequest(cr); err != nil {
 		return err
@@ -3,6 +3,7 @@ package parse
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"path"
 	"reflect"
@@ -10,17 +11,22 @@ import (
 
 // Delete the instance of the type represented by v from the Parse database. If
 // useMasteKey=true, the Master Key will be used for the deletion request.
-func Delete(v interface{}, useMasterKey bool) error {
-	return _delete(v, useMasterKey, nil)
+func Delete(v interface{}, useMasterKey bool, opts ...RequestOption) error {
+	return _delete(v, useMasterKey, nil, opts...)
 }
 
-func _delete(v interface{}, useMasterKey bool, currentSession *sessionT) error {
+func _delete(v interface{}, useMasterKey bool, currentSession *sessionT, opts ...RequestOption) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return errors.New("v must be a non-nil pointer")
 	}
 
-	_, err := defaultClient.doRequest(&deleteT{inst: v, shouldUseMasterKey: useMasterKey, currentSession: currentSession})
+	_, err := defaultClient.doRequest(&deleteT{
+		inst:               v,
+		shouldUseMasterKey: useMasterKey,
+		currentSession:     currentSession,
+		headers:            optionHeaders(opts),
+	})
 	return err
 }
 
@@ -28,6 +34,7 @@ type deleteT struct {
 	inst               interface{}
 	shouldUseMasterKey bool
 	currentSession     *sessionT
+	headers            http.Header
 }
 
 func (d *deleteT) method() string {
@@ -72,3 +79,7 @@ func (d *deleteT) session() *sessionT {
 func (d *deleteT) contentType() string {
 	return "application/x-www-form-urlencoded"
 }
+
+func (d *deleteT) requestHeaders() http.Header {
+	return d.headers
+}
@@ -0,0 +1,105 @@
+package parse
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// A RequestDescription describes a request to Parse without sending it.
+// Keys and session tokens in Header, and passwords in URL, are redacted.
+type RequestDescription struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   string
+}
+
+// Returns a curl command that sends the described request. Redacted
+// values must be filled in before running it.
+func (d *RequestDescription) Curl() string {
+	parts := []string{"curl", "-X", d.Method}
+
+	keys := make([]string, 0, len(d.Header))
+	for k := range d.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range d.Header[k] {
+			if k == "Accept-Encoding" && v == "gzip" {
+				parts = append(parts, "--compressed")
+				continue
+			}
+			parts = append(parts, "-H", shellQuote(k+": "+v))
+		}
+	}
+
+	if d.Body != "" {
+		parts = append(parts, "--data-binary", shellQuote(d.Body))
+	}
+
+	parts = append(parts, shellQuote(d.URL))
+	return strings.Join(parts, " ")
+}
+
+// Returns an *http.Request for the described request. Redacted values must
+// be filled in before sending it.
+func (d *RequestDescription) HTTPRequest() (*http.Request, error) {
+	var body io.Reader
+	if d.Body != "" {
+		body = strings.NewReader(d.Body)
+	}
+
+	req, err := http.NewRequest(d.Method, d.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range d.Header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	return req, nil
+}
+
+// Describe the function call specified by name and params without
+// calling it
+func DescribeFunction(name string, params Params) (*RequestDescription, error) {
+	if params == nil {
+		params = Params{}
+	}
+	return describe(&callFnT{name: name, params: params})
+}
+
+func describe(op requestT) (*RequestDescription, error) {
+	req, err := defaultClient.newRequest(op)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &RequestDescription{
+		Method: req.Method,
+		URL:    redactURL(req.URL.String()),
+		Header: redactHeaders(req.Header),
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		d.Body = string(b)
+	}
+	return d, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
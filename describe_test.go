@@ -0,0 +1,66 @@
+package parse
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDescribeQuery(t *testing.T) {
+	us := make([]User, 0)
+	q, _ := NewQuery(&us)
+	q.EqualTo("city", "Chicago").Limit(10)
+	q.UseMasterKey()
+
+	d, err := q.Describe()
+	if err != nil {
+		t.Fatalf("unexpected error describing query: %v\n", err)
+	}
+
+	if d.Method != "GET" {
+		t.Errorf("expected method GET. Got %s\n", d.Method)
+	}
+
+	u, _ := url.Parse(d.URL)
+	if u.Path != "/1/users" {
+		t.Errorf("unexpected path. Got %s\n", u.Path)
+	}
+	if w := u.Query().Get("where"); w != `{"city":"Chicago"}` {
+		t.Errorf("unexpected where. Got %s\n", w)
+	}
+
+	if k := d.Header.Get(MasterKeyHeader); k != redacted {
+		t.Errorf("master key was not redacted. Got %s\n", k)
+	}
+
+	c := d.Curl()
+	if strings.Contains(c, "master_key") {
+		t.Errorf("curl command contained master key: %s\n", c)
+	}
+	if !strings.HasPrefix(c, "curl -X GET ") || !strings.Contains(c, "--compressed") {
+		t.Errorf("unexpected curl command: %s\n", c)
+	}
+}
+
+func TestDescribeFunction(t *testing.T) {
+	d, err := DescribeFunction("hello", Params{"name": "it's me"})
+	if err != nil {
+		t.Fatalf("unexpected error describing function: %v\n", err)
+	}
+
+	if d.Body != `{"name":"it's me"}` {
+		t.Errorf("unexpected body. Got %s\n", d.Body)
+	}
+
+	if c := d.Curl(); !strings.Contains(c, `--data-binary '{"name":"it'\''s me"}'`) {
+		t.Errorf("body was not quoted in curl command: %s\n", c)
+	}
+
+	req, err := d.HTTPRequest()
+	if err != nil {
+		t.Fatalf("unexpected error building request: %v\n", err)
+	}
+	if req.Method != "POST" || req.URL.Path != "/1/functions/hello" || req.Header.Get(RestKeyHeader) != redacted {
+		t.Errorf("unexpected request: %v\n", req)
+	}
+}
@@ -0,0 +1,218 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Error codes returned by Parse. See the REST API guide for details:
+// https://docs.parseplatform.org/rest/guide/#error-codes
+const (
+	// Returned for errors that did not include a Parse error code - e.g. a
+	// proxy returning an HTML error page
+	CodeOtherCause = -1
+
+	CodeInternalServerError         = 1
+	CodeServiceUnavailable          = 2
+	CodeClientDisconnected          = 4
+	CodeConnectionFailed            = 100
+	CodeObjectNotFound              = 101
+	CodeInvalidQuery                = 102
+	CodeInvalidClassName            = 103
+	CodeMissingObjectId             = 104
+	CodeInvalidKeyName              = 105
+	CodeInvalidPointer              = 106
+	CodeInvalidJSON                 = 107
+	CodeCommandUnavailable          = 108
+	CodeNotInitialized              = 109
+	CodeIncorrectType               = 111
+	CodeInvalidChannelName          = 112
+	CodePushMisconfigured           = 115
+	CodeObjectTooLarge              = 116
+	CodeOperationForbidden          = 119
+	CodeCacheMiss                   = 120
+	CodeInvalidNestedKey            = 121
+	CodeInvalidFileName             = 122
+	CodeInvalidACL                  = 123
+	CodeTimeout                     = 124
+	CodeInvalidEmailAddress         = 125
+	CodeMissingContentType          = 126
+	CodeMissingContentLength        = 127
+	CodeInvalidContentLength        = 128
+	CodeFileTooLarge                = 129
+	CodeFileSaveError               = 130
+	CodeDuplicateValue              = 137
+	CodeInvalidRoleName             = 139
+	CodeExceededQuota               = 140
+	CodeScriptFailed                = 141
+	CodeValidationFailed            = 142
+	CodeInvalidImageData            = 150
+	CodeUnsavedFileError            = 151
+	CodeInvalidPushTimeError        = 152
+	CodeFileDeleteError             = 153
+	CodeInefficientQueryError       = 154
+	CodeRequestLimitExceeded        = 155
+	CodeDuplicateRequest            = 159
+	CodeInvalidEventName            = 160
+	CodeFileDeleteUnnamedError      = 161
+	CodeInvalidValue                = 162
+	CodeUsernameMissing             = 200
+	CodePasswordMissing             = 201
+	CodeUsernameTaken               = 202
+	CodeEmailTaken                  = 203
+	CodeEmailMissing                = 204
+	CodeEmailNotFound               = 205
+	CodeSessionMissing              = 206
+	CodeMustCreateUserThroughSignup = 207
+	CodeAccountAlreadyLinked        = 208
+	CodeInvalidSessionToken         = 209
+	CodeMFAError                    = 210
+	CodeMFATokenRequired            = 211
+	CodeLinkedIdMissing             = 250
+	CodeInvalidLinkedSession        = 251
+	CodeUnsupportedService          = 252
+	CodeInvalidSchemaOperation      = 255
+	CodeAggregateError              = 600
+	CodeFileReadError               = 601
+	CodeXDomainRequest              = 602
+)
+
+// Errors that may be compared against errors returned by this package
+// using errors.Is. Any ParseError with the same code matches, e.g.:
+//
+// if errors.Is(err, parse.ErrObjectNotFound) { ... }
+var (
+	ErrInternalServerError  ParseError = codeError(CodeInternalServerError, "internal server error")
+	ErrObjectNotFound       ParseError = codeError(CodeObjectNotFound, "object not found")
+	ErrInvalidQuery         ParseError = codeError(CodeInvalidQuery, "invalid query")
+	ErrInvalidClassName     ParseError = codeError(CodeInvalidClassName, "invalid class name")
+	ErrInvalidJSON          ParseError = codeError(CodeInvalidJSON, "invalid JSON")
+	ErrIncorrectType        ParseError = codeError(CodeIncorrectType, "incorrect type")
+	ErrOperationForbidden   ParseError = codeError(CodeOperationForbidden, "operation forbidden")
+	ErrTimeout              ParseError = codeError(CodeTimeout, "timeout")
+	ErrDuplicateValue       ParseError = codeError(CodeDuplicateValue, "duplicate value")
+	ErrScriptFailed         ParseError = codeError(CodeScriptFailed, "script failed")
+	ErrValidationFailed     ParseError = codeError(CodeValidationFailed, "validation failed")
+	ErrRequestLimitExceeded ParseError = codeError(CodeRequestLimitExceeded, "request limit exceeded")
+	ErrUsernameMissing      ParseError = codeError(CodeUsernameMissing, "username missing")
+	ErrPasswordMissing      ParseError = codeError(CodePasswordMissing, "password missing")
+	ErrUsernameTaken        ParseError = codeError(CodeUsernameTaken, "username taken")
+	ErrEmailTaken           ParseError = codeError(CodeEmailTaken, "email taken")
+	ErrEmailNotFound        ParseError = codeError(CodeEmailNotFound, "email not found")
+	ErrSessionMissing       ParseError = codeError(CodeSessionMissing, "session missing")
+	ErrAccountAlreadyLinked ParseError = codeError(CodeAccountAlreadyLinked, "account already linked")
+	ErrInvalidSessionToken  ParseError = codeError(CodeInvalidSessionToken, "invalid session token")
+)
+
+// An error returned by Parse, or by a server or proxy in front of it
+type ParseError interface {
+	error
+
+	// The Parse error code - one of the Code* constants, or CodeOtherCause
+	// if the response did not include a code
+	Code() int
+
+	// The error message returned by Parse
+	Message() string
+
+	// The HTTP status code of the response
+	HTTPStatus() int
+
+	// The HTTP method of the request that failed
+	RequestMethod() string
+
+	// The URL of the request that failed. Passwords in the query string
+	// are redacted.
+	RequestEndpoint() string
+
+	// The raw body of the response
+	ResponseBody() []byte
+}
+
+type parseErrorT struct {
+	ErrorCode    int    `json:"code" parse:"code"`
+	ErrorMessage string `json:"error" parse:"error"`
+
+	status   int
+	method   string
+	endpoint string
+	body     []byte
+}
+
+func codeError(code int, msg string) *parseErrorT {
+	return &parseErrorT{ErrorCode: code, ErrorMessage: msg}
+}
+
+// Builds the error for a non-2xx response. Responses that aren't a Parse
+// error object - e.g. an HTML error page from a proxy - produce an error
+// with code CodeOtherCause.
+func newParseError(status int, method, endpoint string, body []byte) *parseErrorT {
+	e := &parseErrorT{}
+	if err := json.Unmarshal(body, e); err != nil || (e.ErrorCode == 0 && e.ErrorMessage == "") {
+		e.ErrorCode = CodeOtherCause
+		e.ErrorMessage = fmt.Sprintf("unexpected response: %d %s", status, http.StatusText(status))
+	}
+
+	e.status = status
+	e.method = method
+	e.endpoint = redactURL(endpoint)
+	e.body = body
+	return e
+}
+
+func (e *parseErrorT) Error() string {
+	return fmt.Sprintf("error %d - %s", e.ErrorCode, e.ErrorMessage)
+}
+
+func (e *parseErrorT) Code() int {
+	return e.ErrorCode
+}
+
+func (e *parseErrorT) Message() string {
+	return e.ErrorMessage
+}
+
+func (e *parseErrorT) HTTPStatus() int {
+	return e.status
+}
+
+func (e *parseErrorT) RequestMethod() string {
+	return e.method
+}
+
+func (e *parseErrorT) RequestEndpoint() string {
+	return e.endpoint
+}
+
+func (e *parseErrorT) ResponseBody() []byte {
+	return e.body
+}
+
+// Reports whether target is a ParseError with the same code, so that errors
+// may be matched against the Err* values with errors.Is
+func (e *parseErrorT) Is(target error) bool {
+	if t, ok := target.(ParseError); ok {
+		return t.Code() == e.ErrorCode
+	}
+	return false
+}
+
+const redacted = "********"
+
+// Returns u with any password in its query string redacted
+func redactURL(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil || !strings.Contains(pu.RawQuery, "password") {
+		return u
+	}
+
+	q := pu.Query()
+	if _, ok := q["password"]; ok {
+		q.Set("password", redacted)
+	}
+	pu.RawQuery = q.Encode()
+	return pu.String()
+}
@@ -0,0 +1,124 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorDetails(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"code":101,"error":"object not found for get"}`)
+	})
+	defer teardownTestServer()
+
+	u := User{}
+	q, _ := NewQuery(&u)
+	err := q.Get("abc123")
+	if err == nil {
+		t.Errorf("Get should return an error")
+		t.FailNow()
+	}
+
+	pe, ok := err.(ParseError)
+	if !ok {
+		t.Errorf("Get should return a ParseError. Got: %T\n", err)
+		t.FailNow()
+	}
+
+	if pe.Code() != CodeObjectNotFound {
+		t.Errorf("wrong error code. Got [%d] expected [%d]\n", pe.Code(), CodeObjectNotFound)
+	}
+
+	if pe.Message() != "object not found for get" {
+		t.Errorf("wrong error message. Got [%s]\n", pe.Message())
+	}
+
+	if pe.HTTPStatus() != http.StatusNotFound {
+		t.Errorf("wrong HTTP status. Got [%d] expected [%d]\n", pe.HTTPStatus(), http.StatusNotFound)
+	}
+
+	if pe.RequestMethod() != "GET" {
+		t.Errorf("wrong request method. Got [%s]\n", pe.RequestMethod())
+	}
+
+	if !strings.HasSuffix(pe.RequestEndpoint(), "/1/users/abc123") {
+		t.Errorf("wrong request endpoint. Got [%s]\n", pe.RequestEndpoint())
+	}
+
+	if string(pe.ResponseBody()) != `{"code":101,"error":"object not found for get"}` {
+		t.Errorf("wrong response body. Got [%s]\n", pe.ResponseBody())
+	}
+
+	if !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("error should match ErrObjectNotFound")
+	}
+
+	if errors.Is(err, ErrInvalidSessionToken) {
+		t.Errorf("error should not match ErrInvalidSessionToken")
+	}
+
+	wrapped := fmt.Errorf("loading user: %w", err)
+	if !errors.Is(wrapped, ErrObjectNotFound) {
+		t.Errorf("wrapped error should match ErrObjectNotFound")
+	}
+}
+
+func TestParseErrorNonJSONBody(t *testing.T) {
+	body := `<html><body><h1>502 Bad Gateway</h1></body></html>`
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, body)
+	})
+	defer teardownTestServer()
+
+	err := Create(&User{}, false)
+	pe, ok := err.(ParseError)
+	if !ok {
+		t.Errorf("Create should return a ParseError. Got: %T %v\n", err, err)
+		t.FailNow()
+	}
+
+	if pe.Code() != CodeOtherCause {
+		t.Errorf("wrong error code. Got [%d] expected [%d]\n", pe.Code(), CodeOtherCause)
+	}
+
+	if pe.HTTPStatus() != http.StatusBadGateway {
+		t.Errorf("wrong HTTP status. Got [%d] expected [%d]\n", pe.HTTPStatus(), http.StatusBadGateway)
+	}
+
+	if pe.RequestMethod() != "POST" {
+		t.Errorf("wrong request method. Got [%s]\n", pe.RequestMethod())
+	}
+
+	if string(pe.ResponseBody()) != body {
+		t.Errorf("wrong response body. Got [%s]\n", pe.ResponseBody())
+	}
+}
+
+func TestParseErrorRedactsPassword(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"code":101,"error":"invalid login parameters"}`)
+	})
+	defer teardownTestServer()
+
+	_, err := Login("username", "secret", nil)
+	pe, ok := err.(ParseError)
+	if !ok {
+		t.Errorf("Login should return a ParseError. Got: %T %v\n", err, err)
+		t.FailNow()
+	}
+
+	if strings.Contains(pe.RequestEndpoint(), "secret") {
+		t.Errorf("error endpoint contains password: %s\n", pe.RequestEndpoint())
+	}
+
+	if !strings.Contains(pe.RequestEndpoint(), "username=username") {
+		t.Errorf("error endpoint missing username: %s\n", pe.RequestEndpoint())
+	}
+}
@@ -0,0 +1,194 @@
+package parse
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+// Refresh the instance of the type pointed to by v with its current state
+// in the Parse database, identified by its Id field. Fields not returned by
+// Parse are reset to their zero values. If useMasterKey=true, the Master Key
+// will be used for the request.
+func Fetch(v interface{}, useMasterKey bool) error {
+	return fetch(v, useMasterKey, nil, nil)
+}
+
+// Refresh v as with Fetch, also retrieving the objects referenced by the
+// fields specified by keys
+func FetchWithInclude(v interface{}, useMasterKey bool, keys ...string) error {
+	return fetch(v, useMasterKey, nil, keys)
+}
+
+// Refresh v as with Fetch, unless it has already been populated by Parse,
+// e.g. by a query that included it. An object whose CreatedAt field is set
+// is considered populated.
+func FetchIfNeeded(v interface{}, useMasterKey bool) error {
+	if isFetched(v) {
+		return nil
+	}
+	return fetch(v, useMasterKey, nil, nil)
+}
+
+// Refresh each object in the slice pointed to by v, as with Fetch, querying
+// for up to 100 objects at a time. Returns ErrObjectNotFound, leaving v
+// unchanged, if any of the objects don't exist.
+func FetchAll(v interface{}, useMasterKey bool) error {
+	return fetchAll(v, useMasterKey, nil)
+}
+
+func (s *sessionT) Fetch(v interface{}) error {
+	return fetch(v, false, s, nil)
+}
+
+func (s *sessionT) FetchWithInclude(v interface{}, keys ...string) error {
+	return fetch(v, false, s, keys)
+}
+
+func (s *sessionT) FetchIfNeeded(v interface{}) error {
+	if isFetched(v) {
+		return nil
+	}
+	return fetch(v, false, s, nil)
+}
+
+func (s *sessionT) FetchAll(v interface{}) error {
+	return fetchAll(v, false, s)
+}
+
+// The maximum number of objects retrieved by each query made by FetchAll
+const fetchAllBatchSize = 100
+
+func fetch(v interface{}, useMasterKey bool, currentSession *sessionT, keys []string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("v must be a non-nil pointer to a struct")
+	}
+
+	id, err := objectId(rv.Elem())
+	if err != nil {
+		return err
+	}
+
+	// Fetch into a new value, so that v is only replaced on success
+	nv := reflect.New(rv.Elem().Type())
+	q, _ := NewQuery(nv.Interface())
+	q.(*queryT).currentSession = currentSession
+	if useMasterKey {
+		q.UseMasterKey()
+	}
+	if len(keys) > 0 {
+		q.Include(keys...)
+	}
+
+	if err := q.Get(id); err != nil {
+		return err
+	}
+	rv.Elem().Set(nv.Elem())
+	return nil
+}
+
+func fetchAll(v interface{}, useMasterKey bool, currentSession *sessionT) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("v must be a non-nil pointer to a slice")
+	}
+
+	rvi := rv.Elem()
+	if rvi.Len() == 0 {
+		return nil
+	}
+
+	et := rvi.Type().Elem()
+	isPtr := et.Kind() == reflect.Ptr
+	if isPtr {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Struct {
+		return errors.New("v must be a pointer to a slice of structs or pointers to structs")
+	}
+
+	ids := make([]interface{}, 0, rvi.Len())
+	for i := 0; i < rvi.Len(); i++ {
+		ev := reflect.Indirect(rvi.Index(i))
+		if !ev.IsValid() {
+			return errors.New("v must not contain nil values")
+		}
+
+		id, err := objectId(ev)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	// Query in batches, so that neither the server's maximum limit nor the
+	// length of the request URL is exceeded
+	byId := make(map[string]reflect.Value, len(ids))
+	for start := 0; start < len(ids); start += fetchAllBatchSize {
+		batch := ids[start:]
+		if len(batch) > fetchAllBatchSize {
+			batch = batch[:fetchAllBatchSize]
+		}
+
+		results := reflect.New(reflect.SliceOf(reflect.PtrTo(et)))
+		results.Elem().Set(reflect.MakeSlice(results.Elem().Type(), 0, len(batch)))
+		q, _ := NewQuery(results.Interface())
+		q.(*queryT).currentSession = currentSession
+		if useMasterKey {
+			q.UseMasterKey()
+		}
+		q.In("objectId", batch...).Limit(len(batch))
+
+		if err := q.Find(); err != nil && err != ErrNoRows {
+			return err
+		}
+
+		for i := 0; i < results.Elem().Len(); i++ {
+			r := results.Elem().Index(i)
+			byId[r.Elem().FieldByName("Id").String()] = r
+		}
+	}
+
+	for _, id := range ids {
+		if _, ok := byId[id.(string)]; !ok {
+			return ErrObjectNotFound
+		}
+	}
+
+	for i, id := range ids {
+		r := byId[id.(string)]
+		if isPtr {
+			if rvi.Index(i).IsNil() {
+				rvi.Index(i).Set(reflect.New(et))
+			}
+			rvi.Index(i).Elem().Set(r.Elem())
+		} else {
+			rvi.Index(i).Set(r.Elem())
+		}
+	}
+	return nil
+}
+
+// Reports whether v points to an object that has already been populated by
+// Parse, i.e. whose CreatedAt field is set
+func isFetched(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	f := rv.Elem().FieldByName("CreatedAt")
+	return f.IsValid() && f.Type() == timeType && !f.Interface().(time.Time).IsZero()
+}
+
+// Returns the value of the Id field of the struct value v
+func objectId(v reflect.Value) (string, error) {
+	f := v.FieldByName("Id")
+	if !f.IsValid() || f.Kind() != reflect.String {
+		return "", errors.New("type has no Id field")
+	}
+	if f.String() == "" {
+		return "", errors.New("Id field must not be empty")
+	}
+	return f.String(), nil
+}
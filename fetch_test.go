@@ -0,0 +1,182 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetch(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/classes/TrackedGame/g1" {
+			t.Errorf("unexpected path: %s\n", r.URL.Path)
+		}
+		if inc := r.URL.Query().Get("include"); inc != "owner" {
+			t.Errorf("unexpected include. Got [%s]\n", inc)
+		}
+		fmt.Fprintf(w, `{"objectId":"g1","name":"chess","createdAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	g := &TrackedGame{Base: Base{Id: "g1"}, Score: 10}
+	if err := FetchWithInclude(g, false, "owner"); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	if g.Name != "chess" || g.Score != 0 || g.CreatedAt.IsZero() {
+		t.Errorf("object was not refreshed: %+v\n", g)
+	}
+}
+
+func TestFetchLeavesObjectOnError(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"code":101,"error":"object not found for get"}`)
+	})
+	defer teardownTestServer()
+
+	g := &TrackedGame{Base: Base{Id: "g1"}, Score: 10}
+	if err := Fetch(g, false); err == nil {
+		t.Errorf("expected error")
+	}
+	if g.Score != 10 {
+		t.Errorf("object should not have been modified: %+v\n", g)
+	}
+
+	if err := Fetch(&TrackedGame{}, false); err == nil {
+		t.Errorf("expected error fetching object without an Id")
+	}
+}
+
+func TestFetchIfNeeded(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"objectId":"g1","name":"chess","createdAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	populated := &TrackedGame{Base: Base{Id: "g1", CreatedAt: time.Now()}}
+	if err := FetchIfNeeded(populated, false); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+	if requests != 0 {
+		t.Errorf("populated object should not have been fetched")
+	}
+
+	stub := &TrackedGame{Base: Base{Id: "g1"}}
+	if err := FetchIfNeeded(stub, false); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+	if requests != 1 || stub.Name != "chess" {
+		t.Errorf("stub object should have been fetched: %+v\n", stub)
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if w := r.URL.Query().Get("where"); w != `{"objectId":{"$in":["g1","g2"]}}` {
+			t.Errorf("unexpected where. Got %s\n", w)
+		}
+		if l := r.URL.Query().Get("limit"); l != "2" {
+			t.Errorf("unexpected limit. Got %s\n", l)
+		}
+		fmt.Fprintf(w, `{"results":[{"objectId":"g2","name":"go"},{"objectId":"g1","name":"chess"}]}`)
+	})
+	defer teardownTestServer()
+
+	games := []TrackedGame{{Base: Base{Id: "g1"}}, {Base: Base{Id: "g2"}}}
+	if err := FetchAll(&games, false); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if games[0].Name != "chess" || games[1].Name != "go" {
+		t.Errorf("objects were not refreshed in order: %+v\n", games)
+	}
+
+	ptrs := []*TrackedGame{{Base: Base{Id: "g1"}}, {Base: Base{Id: "g2"}}}
+	if err := FetchAll(&ptrs, false); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if ptrs[0].Name != "chess" || ptrs[1].Name != "go" {
+		t.Errorf("objects were not refreshed in order: %+v %+v\n", ptrs[0], ptrs[1])
+	}
+}
+
+func TestFetchAllBatches(t *testing.T) {
+	limits := []string{}
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		limits = append(limits, r.URL.Query().Get("limit"))
+
+		where := map[string]map[string][]string{}
+		json.Unmarshal([]byte(r.URL.Query().Get("where")), &where)
+		results := []string{}
+		for _, id := range where["objectId"]["$in"] {
+			results = append(results, fmt.Sprintf(`{"objectId":%q,"name":"name %s"}`, id, id))
+		}
+		fmt.Fprintf(w, `{"results":[%s]}`, strings.Join(results, ","))
+	})
+	defer teardownTestServer()
+
+	games := make([]TrackedGame, 250)
+	for i := range games {
+		games[i].Id = fmt.Sprintf("g%d", i)
+	}
+	if err := FetchAll(&games, false); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	if strings.Join(limits, ",") != "100,100,50" {
+		t.Errorf("unexpected batches. Got limits %v\n", limits)
+	}
+	for _, g := range games {
+		if g.Name != "name "+g.Id {
+			t.Errorf("object was not refreshed: %+v\n", g)
+			break
+		}
+	}
+}
+
+func TestSessionFetchVariants(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if h := r.Header.Get(SessionTokenHeader); h != "r:abcd" {
+			t.Errorf("request did not use session token. Got [%s]\n", h)
+		}
+		if requests == 1 && r.URL.Query().Get("include") != "owner" {
+			t.Errorf("unexpected include. Got %s\n", r.URL.Query().Get("include"))
+		}
+		fmt.Fprintf(w, `{"objectId":"g1","name":"chess","createdAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	s := &sessionT{user: &User{}, sessionToken: "r:abcd"}
+	g := &TrackedGame{Base: Base{Id: "g1"}}
+	if err := s.FetchWithInclude(g, "owner"); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if err := s.FetchIfNeeded(g); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if requests != 1 || g.Name != "chess" {
+		t.Errorf("expected a single fetch. Got %d requests: %+v\n", requests, g)
+	}
+}
+
+func TestFetchAllMissingObject(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"results":[{"objectId":"g1","name":"chess"}]}`)
+	})
+	defer teardownTestServer()
+
+	games := []TrackedGame{{Base: Base{Id: "g1"}}, {Base: Base{Id: "g2"}}}
+	if err := FetchAll(&games, false); err != ErrObjectNotFound {
+		t.Errorf("expected ErrObjectNotFound. Got %v\n", err)
+	}
+	if games[0].Name != "" {
+		t.Errorf("objects should not have been modified: %+v\n", games)
+	}
+}
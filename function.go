@@ -3,6 +3,7 @@ package parse
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
 	"net/url"
 	"path"
 	"reflect"
@@ -10,14 +11,15 @@ import (
 
 type Params map[string]interface{}
 
-func CallFunction(name string, params Params, resp interface{}) error {
-	return callFn(name, params, resp, nil)
+func CallFunction(name string, params Params, resp interface{}, opts ...RequestOption) error {
+	return callFn(name, params, resp, nil, opts...)
 }
 
 type callFnT struct {
 	name           string
 	params         Params
 	currentSession *sessionT
+	headers        http.Header
 }
 
 func (c *callFnT) method() string {
@@ -51,11 +53,15 @@ func (c *callFnT) contentType() string {
 	return "application/json"
 }
 
+func (c *callFnT) requestHeaders() http.Header {
+	return c.headers
+}
+
 type fnRespT struct {
 	Result interface{} `parse:"result"`
 }
 
-func callFn(name string, params Params, resp interface{}, currentSession *sessionT) error {
+func callFn(name string, params Params, resp interface{}, currentSession *sessionT, opts ...RequestOption) error {
 	rv := reflect.ValueOf(resp)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return errors.New("resp must be a non-nil pointer")
@@ -69,6 +75,7 @@ func callFn(name string, params Params, resp interface{}, currentSession *sessio
 		name:           name,
 		params:         params,
 		currentSession: currentSession,
+		headers:        optionHeaders(opts),
 	}
 	if b, err := defaultClient.doRequest(cr); err != nil {
 		return err
@@ -0,0 +1,171 @@
+package parse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A Logger receives debug logs describing each request sent to Parse. Each
+// call provides a message followed by alternating keys and values. A
+// *slog.Logger satisfies this interface.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+// Log each request sent to Parse to l. Each request is logged with its method,
+// endpoint, headers, response status, and duration. Keys, session tokens, and
+// passwords are always masked. Pass nil to disable logging.
+//
+// Returns an error if called before parse.Initialize
+func SetLogger(l Logger) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetLogger")
+	}
+
+	defaultClient.logger = l
+	return nil
+}
+
+// Include request and response bodies in logs. Passwords, session tokens,
+// and auth data tokens within bodies are masked.
+//
+// Note that logging response bodies requires reading each response in its
+// entirety before decoding it.
+//
+// Returns an error if called before parse.Initialize
+func SetLogBodies(b bool) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetLogBodies")
+	}
+
+	defaultClient.logBodies = b
+	return nil
+}
+
+// Headers whose values are never logged
+var sensitiveHeaders = map[string]bool{
+	http.CanonicalHeaderKey(MasterKeyHeader):      true,
+	http.CanonicalHeaderKey(RestKeyHeader):        true,
+	http.CanonicalHeaderKey(SessionTokenHeader):   true,
+	http.CanonicalHeaderKey(ClientKeyHeader):      true,
+	http.CanonicalHeaderKey(MaintenanceKeyHeader): true,
+	http.CanonicalHeaderKey(JavascriptKeyHeader):  true,
+}
+
+// Fields whose values are never logged when they appear in a request or
+// response body
+var sensitiveFields = map[string]bool{
+	"password":          true,
+	"sessionToken":      true,
+	"masterKey":         true,
+	"access_token":      true,
+	"auth_token":        true,
+	"auth_token_secret": true,
+	"consumer_secret":   true,
+	"id_token":          true,
+	"token":             true,
+}
+
+func (c *clientT) logRequest(info *RequestInfo, req *http.Request, resp *http.Response, err error, d time.Duration) {
+	args := []interface{}{
+		"kind", info.Kind.String(),
+		"className", info.ClassName,
+		"method", req.Method,
+		"endpoint", redactURL(req.URL.String()),
+		"headers", redactHeaders(req.Header),
+		"duration", d,
+	}
+
+	if c.logBodies && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := ioutil.ReadAll(body)
+			args = append(args, "requestBody", redactBody(b))
+		}
+	}
+
+	if err != nil {
+		args = append(args, "error", err.Error())
+		c.logger.Debug("parse request failed", args...)
+		return
+	}
+
+	args = append(args, "status", resp.StatusCode)
+	if c.logBodies {
+		if b, err := peekResponseBody(resp); err == nil {
+			args = append(args, "responseBody", redactBody(b))
+		}
+	}
+
+	c.logger.Debug("parse request", args...)
+}
+
+func redactHeaders(h http.Header) http.Header {
+	rh := make(http.Header, len(h))
+	for k, v := range h {
+		if sensitiveHeaders[http.CanonicalHeaderKey(k)] {
+			rh[k] = []string{redacted}
+		} else {
+			rh[k] = v
+		}
+	}
+	return rh
+}
+
+// Reads the body of resp, replacing it with a copy so that it may still be
+// read by the caller. Returns the decompressed body.
+func peekResponseBody(resp *http.Response) ([]byte, error) {
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	}
+	return b, nil
+}
+
+// Returns the body b with the values of any sensitive fields masked. Bodies
+// that aren't JSON are returned as is.
+func redactBody(b []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return string(b)
+	}
+
+	rb, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return string(b)
+	}
+	return string(rb)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range t {
+			if sensitiveFields[k] || strings.HasSuffix(k, "Token") {
+				t[k] = redacted
+			} else {
+				t[k] = redactValue(fv)
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = redactValue(e)
+		}
+	}
+	return v
+}
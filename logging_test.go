@@ -0,0 +1,50 @@
+package parse
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type testLogger struct {
+	msgs []string
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) {
+	l.msgs = append(l.msgs, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+
+func TestLoggerMasksSecrets(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"objectId":"abc","username":"kylemcc","sessionToken":"r:secret_token"}`)
+	})
+	defer teardownTestServer()
+
+	l := &testLogger{}
+	SetLogger(l)
+	SetLogBodies(true)
+	defer SetLogger(nil)
+	defer SetLogBodies(false)
+
+	if _, err := Login("kylemcc", "super_secret", nil); err != nil {
+		t.Errorf("unexpected error logging in: %v\n", err)
+	}
+
+	if len(l.msgs) != 1 {
+		t.Fatalf("expected 1 log message. Got %d\n", len(l.msgs))
+	}
+
+	msg := l.msgs[0]
+	for _, s := range []string{"super_secret", "r:secret_token", "master_key", "rest_key"} {
+		if strings.Contains(msg, s) {
+			t.Errorf("log message contained secret %q: %s\n", s, msg)
+		}
+	}
+
+	for _, s := range []string{"GET", "/1/login", "kylemcc", "200", "login"} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("log message did not contain %q: %s\n", s, msg)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package parse
+
+import (
+	"net/http"
+	"time"
+)
+
+// The kind of operation performed by a request
+type OperationKind int
+
+const (
+	OpOther OperationKind = iota
+	OpQuery
+	OpCreate
+	OpUpdate
+	OpDelete
+	OpFunction
+	OpPush
+	OpLogin
+	OpConfig
+	OpHealthCheck
+	OpLogout
+)
+
+func (k OperationKind) String() string {
+	switch k {
+	case OpQuery:
+		return "query"
+	case OpCreate:
+		return "create"
+	case OpUpdate:
+		return "update"
+	case OpDelete:
+		return "delete"
+	case OpFunction:
+		return "function"
+	case OpPush:
+		return "push"
+	case OpLogin:
+		return "login"
+	case OpConfig:
+		return "config"
+	case OpHealthCheck:
+		return "healthCheck"
+	case OpLogout:
+		return "logout"
+	}
+
+	return "other"
+}
+
+// Describes a request being sent to Parse, for use by middleware
+type RequestInfo struct {
+	// The kind of operation being performed
+	Kind OperationKind
+
+	// The class the operation acts on, or an empty string for operations
+	// that don't act on a class (e.g. cloud functions or push)
+	ClassName string
+
+	// The session the request is made on behalf of, or nil if the request
+	// is made with the REST API key or Master Key
+	Session Session
+}
+
+// A RoundTrip sends the request req to Parse and returns the response
+type RoundTrip func(info *RequestInfo, req *http.Request) (*http.Response, error)
+
+// Middleware wraps a RoundTrip to observe or modify requests and responses.
+// Middleware may add headers to the request, record the response status or
+// timing, or return an error in place of sending the request at all. E.g.:
+//
+//	func timing(next parse.RoundTrip) parse.RoundTrip {
+//		return func(info *parse.RequestInfo, req *http.Request) (*http.Response, error) {
+//			start := time.Now()
+//			resp, err := next(info, req)
+//			log.Printf("%s %s took %s", info.Kind, info.ClassName, time.Since(start))
+//			return resp, err
+//		}
+//	}
+//
+// Middleware must not consume the response body.
+type Middleware func(next RoundTrip) RoundTrip
+
+// Returns the client's RoundTrip, wrapped in its middleware
+func (c *clientT) roundTrip() RoundTrip {
+	rt := func(info *RequestInfo, req *http.Request) (*http.Response, error) {
+		if c.logger == nil {
+			return c.httpClient.Do(req)
+		}
+
+		start := time.Now()
+		resp, err := c.httpClient.Do(req)
+		c.logRequest(info, req, resp, err, time.Since(start))
+		return resp, err
+	}
+
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		rt = c.middleware[i](rt)
+	}
+	return rt
+}
+
+func newRequestInfo(op requestT) *RequestInfo {
+	info := &RequestInfo{}
+	if s := op.session(); s != nil {
+		info.Session = s
+	}
+
+	switch o := op.(type) {
+	case *queryT:
+		info.Kind = OpQuery
+		info.ClassName = o.className
+	case *createT:
+		info.Kind = OpCreate
+		info.ClassName = getClassName(o.v)
+	case *updateT:
+		info.Kind = OpUpdate
+		info.ClassName = getClassName(o.inst)
+	case *deleteT:
+		info.Kind = OpDelete
+		info.ClassName = getClassName(o.inst)
+	case *callFnT:
+		info.Kind = OpFunction
+	case *pushT:
+		info.Kind = OpPush
+		info.ClassName = "_Installation"
+	case *loginRequestT:
+		info.Kind = OpLogin
+		info.ClassName = "_User"
+	case *upgradeSessionT:
+		info.Kind = OpLogin
+		info.ClassName = "_Session"
+	case *loginAsT:
+		info.Kind = OpLogin
+		info.ClassName = "_User"
+	case *logoutT:
+		info.Kind = OpLogout
+		info.ClassName = "_Session"
+	case *userEmailRequestT:
+		info.ClassName = "_User"
+	case *verifyPasswordT:
+		info.Kind = OpLogin
+		info.ClassName = "_User"
+	case *configRequestT:
+		info.Kind = OpConfig
+	case *healthCheckT:
+		info.Kind = OpHealthCheck
+	}
+
+	return info
+}
@@ -0,0 +1,112 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get("X-Trace-Id"); h != "trace" {
+			t.Errorf("request did not have header set by middleware. Got [%s]\n", h)
+		}
+
+		switch r.Method {
+		case "GET":
+			fmt.Fprintf(w, `{"results":[{"objectId":"123"}]}`)
+		case "POST":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"objectId":"abc"}`)
+		default:
+			fmt.Fprintf(w, `{}`)
+		}
+	})
+	defer teardownTestServer()
+
+	type callT struct {
+		name      string
+		kind      OperationKind
+		className string
+		session   bool
+		status    int
+	}
+
+	calls := []callT{}
+	record := func(name string) Middleware {
+		return func(next RoundTrip) RoundTrip {
+			return func(info *RequestInfo, req *http.Request) (*http.Response, error) {
+				if name == "outer" {
+					req.Header.Set("X-Trace-Id", "trace")
+				}
+				resp, err := next(info, req)
+				c := callT{name: name, kind: info.Kind, className: info.ClassName, session: info.Session != nil}
+				if resp != nil {
+					c.status = resp.StatusCode
+				}
+				calls = append(calls, c)
+				return resp, err
+			}
+		}
+	}
+
+	SetMiddleware(record("outer"), record("inner"))
+	defer SetMiddleware()
+
+	us := make([]CustomClass, 0)
+	q, _ := NewQuery(&us)
+	if err := q.Find(); err != nil {
+		t.Errorf("unexpected error running query: %v\n", err)
+	}
+
+	s := &sessionT{user: &User{}, sessionToken: "session_token"}
+	if err := s.Create(&CustomClass{}); err != nil {
+		t.Errorf("unexpected error running create: %v\n", err)
+	}
+
+	if err := Delete(&User{}, false); err != nil {
+		t.Errorf("unexpected error running delete: %v\n", err)
+	}
+
+	expected := []callT{
+		{"inner", OpQuery, "CustomClass", false, 200},
+		{"outer", OpQuery, "CustomClass", false, 200},
+		{"inner", OpCreate, "CustomClass", true, 201},
+		{"outer", OpCreate, "CustomClass", true, 201},
+		{"inner", OpDelete, "_User", false, 200},
+		{"outer", OpDelete, "_User", false, 200},
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("middleware calls different from expected. Got:\n%v\nexpected:\n%v\n", calls, expected)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{}`)
+	})
+	defer teardownTestServer()
+
+	errDenied := errors.New("denied")
+	SetMiddleware(func(next RoundTrip) RoundTrip {
+		return func(info *RequestInfo, req *http.Request) (*http.Response, error) {
+			if info.Kind == OpDelete {
+				return nil, errDenied
+			}
+			return next(info, req)
+		}
+	})
+	defer SetMiddleware()
+
+	if err := Delete(&User{}, false); !errors.Is(err, errDenied) {
+		t.Errorf("expected error from middleware. Got: %v\n", err)
+	}
+
+	if requests != 0 {
+		t.Errorf("request should not have been sent")
+	}
+}
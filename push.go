@@ -3,7 +3,7 @@ package parse
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"net/http"
 	"net/url"
 	"time"
 )
@@ -34,18 +34,27 @@ type PushNotification interface {
 	// Set the payload for this push notification
 	Data(d map[string]interface{}) PushNotification
 
+	// Set a header to be sent with this push request, replacing any value
+	// set by default
+	SetHeader(k, v string) PushNotification
+
 	// Send the push notification
 	Send() error
+
+	// Describe the request Send would send, without sending it
+	Describe() (*RequestDescription, error)
 }
 
 type pushT struct {
 	shouldUseMasterKey bool
+	currentSession     *sessionT
 	channels           []string
 	expirationInterval int64
 	expirationTime     *Date
 	pushTime           *Date
 	where              map[string]interface{}
 	data               map[string]interface{}
+	headers            http.Header
 }
 
 func (p *pushT) method() string {
@@ -82,7 +91,6 @@ func (p *pushT) body() (string, error) {
 		Where:              p.where,
 	})
 
-	fmt.Printf("body: %s\n", payload)
 	return string(payload), err
 }
 
@@ -91,7 +99,7 @@ func (p *pushT) useMasterKey() bool {
 }
 
 func (p *pushT) session() *sessionT {
-	return nil
+	return p.currentSession
 }
 
 func (p *pushT) contentType() string {
@@ -143,12 +151,28 @@ func (p *pushT) Data(d map[string]interface{}) PushNotification {
 	return p
 }
 
+func (p *pushT) SetHeader(k, v string) PushNotification {
+	if p.headers == nil {
+		p.headers = http.Header{}
+	}
+	p.headers.Set(k, v)
+	return p
+}
+
+func (p *pushT) requestHeaders() http.Header {
+	return p.headers
+}
+
+func (p *pushT) Describe() (*RequestDescription, error) {
+	return describe(p)
+}
+
 func (p *pushT) Send() error {
 	b, err := defaultClient.doRequest(p)
-	data := map[string]interface{}{}
-	if err := json.Unmarshal(b, &data); err != nil {
+	if err != nil {
 		return err
 	}
-	fmt.Printf("data: %v\n", data)
-	return err
+
+	data := map[string]interface{}{}
+	return json.Unmarshal(b, &data)
 }
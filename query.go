@@ -1,12 +1,17 @@
 package parse
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"path"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,11 +29,33 @@ const (
 // Returned when a query returns no results
 var ErrNoRows = errors.New("no results returned")
 
+// Returned by Query.Page when a page token is malformed or was issued
+// for a different query
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// A Query is built up by chaining constraints, and is then executed by one
+// of the terminal methods: Get, Find, First, Count, FindAndCount, Page, or
+// Each. Terminal methods execute against a snapshot of the query and never
+// modify it, so a Query may be executed repeatedly, and may be executed
+// from multiple goroutines once it is no longer being modified (each
+// execution still writes its results to the value provided to NewQuery).
 type Query interface {
 
 	// Use the Master Key for the given request.
 	UseMasterKey() Query
 
+	// Use the read-only Master Key for the given request. See
+	// parse.SetReadOnlyMasterKey
+	UseReadOnlyMasterKey() Query
+
+	// Use the Maintenance Key for the given request. See
+	// parse.SetMaintenanceKey
+	UseMaintenanceKey() Query
+
+	// Set a header to be sent with requests made by this query, replacing
+	// any value set by default, e.g. parse.InstallationIdHeader
+	SetHeader(k, v string) Query
+
 	// Get retrieves the instance of the type pointed to by v and
 	// identified by id, and stores the result in v.
 	Get(id string) error
@@ -216,6 +243,44 @@ type Query interface {
 	// Retrieve the number of results that satisfy the given query
 	Count() (int64, error)
 
+	// Retrieves a list of objects that satisfy the given query, along with
+	// the total number of objects that satisfy the query, in a single
+	// request. The results are assigned to the slice provided to NewQuery,
+	// and are subject to the query's limit and skip, while the returned count
+	// is not. Unlike Count, the query is left unmodified and may be reused.
+	//
+	// E.g.:
+	//
+	// users := make([]parse.User, 0)
+	// q, _ := parse.NewQuery(&users)
+	// q.EqualTo("city", "Chicago").Limit(20)
+	// total, err := q.FindAndCount() // Retrieve 20 users, and the total number in Chicago
+	FindAndCount() (int64, error)
+
+	// Describe the request Find would send for this query, without
+	// sending it
+	Describe() (*RequestDescription, error)
+
+	// Retrieves a single page of results, assigning them to the slice
+	// provided to NewQuery, and returns a token identifying the next page.
+	// Pass an empty token to retrieve the first page, and the returned
+	// token to retrieve each subsequent page. An empty token is returned
+	// once the final page has been retrieved.
+	//
+	// Pages are bounded using the query's sort fields plus objectId rather
+	// than a skip, so paging remains fast and stable through large result
+	// sets. Tokens are opaque, and a token is rejected with
+	// ErrInvalidPageToken if the query has changed since it was issued.
+	//
+	// E.g.:
+	//
+	// users := make([]parse.User, 0)
+	// q, _ := parse.NewQuery(&users)
+	// q.OrderBy("-createdAt").Limit(50)
+	// next, err := q.Page("") // first page
+	// next, err = q.Page(next) // second page
+	Page(token string) (string, error)
+
 	requestT
 }
 
@@ -235,8 +300,10 @@ type queryT struct {
 	className string
 
 	currentSession *sessionT
+	headers        http.Header
 
 	shouldUseMasterKey bool
+	key                keyT
 }
 
 // Create a new query instance.
@@ -256,15 +323,45 @@ func NewQuery(v interface{}) (Query, error) {
 	}, nil
 }
 
+func (q *queryT) SetHeader(k, v string) Query {
+	if q.headers == nil {
+		q.headers = http.Header{}
+	}
+	q.headers.Set(k, v)
+	return q
+}
+
+func (q *queryT) requestHeaders() http.Header {
+	return q.headers
+}
+
+func (q *queryT) UseReadOnlyMasterKey() Query {
+	q.shouldUseMasterKey = true
+	q.key = keyReadOnlyMaster
+	return q
+}
+
+func (q *queryT) UseMaintenanceKey() Query {
+	q.shouldUseMasterKey = true
+	q.key = keyMaintenance
+	return q
+}
+
+func (q *queryT) privilegedKey() keyT {
+	return q.key
+}
+
 func (q *queryT) UseMasterKey() Query {
 	q.shouldUseMasterKey = true
+	q.key = keyMaster
 	return q
 }
 
 func (q *queryT) Get(id string) error {
-	q.op = otGet
-	q.instId = &id
-	if body, err := defaultClient.doRequest(q); err != nil {
+	gq := q.Clone().(*queryT)
+	gq.op = otGet
+	gq.instId = &id
+	if body, err := defaultClient.doRequest(gq); err != nil {
 		return err
 	} else {
 		return handleResponse(body, q.inst)
@@ -649,11 +746,17 @@ func (q *queryT) Clone() Query {
 		inst:               q.inst,
 		op:                 q.op,
 		instId:             q.instId,
+		batchSize:          q.batchSize,
 		currentSession:     q.currentSession,
 		shouldUseMasterKey: q.shouldUseMasterKey,
+		key:                q.key,
 		className:          q.className,
 	}
 
+	if q.orderBy != nil {
+		nq.orderBy = append(make([]string, 0, len(q.orderBy)), q.orderBy...)
+	}
+
 	if q.limit != nil {
 		nq.limit = new(int)
 		*nq.limit = *q.limit
@@ -670,10 +773,7 @@ func (q *queryT) Clone() Query {
 	}
 
 	if q.where != nil {
-		nq.where = map[string]interface{}{}
-		for k, v := range q.where {
-			nq.where[k] = v
-		}
+		nq.where = copyConstraints(q.where)
 	}
 
 	if q.include != nil {
@@ -690,9 +790,44 @@ func (q *queryT) Clone() Query {
 		}
 	}
 
+	if q.headers != nil {
+		nq.headers = q.headers.Clone()
+	}
+
 	return &nq
 }
 
+// Returns a deep copy of the constraint map m, so that constraints added to
+// a cloned query don't modify the query it was cloned from
+func copyConstraints(m map[string]interface{}) map[string]interface{} {
+	nm := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		nm[k] = copyConstraint(v)
+	}
+	return nm
+}
+
+func copyConstraint(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return copyConstraints(t)
+	case []map[string]interface{}:
+		nt := make([]map[string]interface{}, 0, len(t))
+		for _, m := range t {
+			nt = append(nt, copyConstraints(m))
+		}
+		return nt
+	case []interface{}:
+		nt := make([]interface{}, 0, len(t))
+		for _, e := range t {
+			nt = append(nt, copyConstraint(e))
+		}
+		return nt
+	default:
+		return v
+	}
+}
+
 func (q *queryT) Sub() Query {
 	q2, _ := NewQuery(q.inst)
 	return q2
@@ -711,7 +846,13 @@ func (q *queryT) Or(qs ...Query) Query {
 
 var chanInterfaceType = reflect.TypeOf(make(chan interface{}, 0))
 
+// Used to stop decoding a batch of results when iteration is cancelled
+var errIterationCancelled = errors.New("iteration cancelled")
+
 func (q *queryT) Each(rc interface{}) (*Iterator, error) {
+	// Iterate over a snapshot of the query so the sort order, limit, and
+	// objectId constraint applied below aren't visible to the caller
+	q = q.Clone().(*queryT)
 	instType := reflect.TypeOf(q.inst)
 	rv := reflect.ValueOf(rc)
 	rt := rv.Type()
@@ -745,21 +886,22 @@ func (q *queryT) Each(rc interface{}) (*Iterator, error) {
 	}
 
 	i := newIterator()
+	i.iterating = true
 
 	go func() {
 		defer func() {
 			rv.Close()
 			close(i.resChan)
+			i.mu.Lock()
 			i.iterating = false
+			i.mu.Unlock()
 		}()
 
-		i.iterating = true
-
-		var sliceType reflect.Type
+		var elemType reflect.Type
 		if rt == chanInterfaceType {
-			sliceType = reflect.SliceOf(instType)
+			elemType = instType
 		} else {
-			sliceType = reflect.SliceOf(rt.Elem())
+			elemType = rt.Elem()
 		}
 
 		crv := reflect.ValueOf(i.cancel)
@@ -781,43 +923,57 @@ func (q *queryT) Each(rc interface{}) (*Iterator, error) {
 			default:
 			}
 
-			s := reflect.New(sliceType)
-			s.Elem().Set(reflect.MakeSlice(sliceType, 0, 100))
-
 			// TODO: handle errors and retry if possible
-			b, err := defaultClient.doRequest(q)
+			body, err := defaultClient.openRequest(q)
 			if err != nil {
-				i.err = err
-				i.resChan <- err
+				i.fail(err)
 				return
 			}
 
-			if err := handleResponse(b, s.Interface()); err != nil && err != ErrNoRows {
-				i.err = err
-				i.resChan <- err
-				return
-			}
+			// Send each result as it is decoded, rather than waiting for
+			// the entire batch
+			var n int
+			var lastId string
+			_, err = decodeResultStream(body, func(dec *json.Decoder) error {
+				var newV reflect.Value
+				if elemType.Kind() == reflect.Ptr {
+					newV = reflect.New(elemType.Elem())
+				} else {
+					newV = reflect.New(elemType)
+				}
 
-			for i := 0; i < s.Elem().Len(); i++ {
-				selectCases[1].Send = s.Elem().Index(i)
-				_case, _, _ := reflect.Select(selectCases)
-				if _case == 0 {
-					break loop
+				if err := decodeResult(dec, newV); err != nil {
+					return err
 				}
-			}
 
-			if s.Elem().Len() < *q.limit {
-				break
-			} else {
-				last := s.Elem().Index(s.Elem().Len() - 1)
-				last = reflect.Indirect(last)
-				if f := last.FieldByName("Id"); f.IsValid() {
-					if id, ok := f.Interface().(string); ok {
-						q.GreaterThan("objectId", id)
-					}
+				if f := newV.Elem().FieldByName("Id"); f.IsValid() {
+					lastId, _ = f.Interface().(string)
+				}
+
+				if elemType.Kind() == reflect.Ptr {
+					selectCases[1].Send = newV
+				} else {
+					selectCases[1].Send = newV.Elem()
+				}
+				if _case, _, _ := reflect.Select(selectCases); _case == 0 {
+					return errIterationCancelled
 				}
+				n++
+				return nil
+			})
+			body.Close()
 
+			if err == errIterationCancelled {
+				break loop
+			} else if err != nil {
+				i.fail(err)
+				return
+			}
+
+			if n < *q.limit || lastId == "" {
+				break
 			}
+			q.GreaterThan("objectId", lastId)
 		}
 		i.resChan <- nil
 	}()
@@ -835,18 +991,43 @@ func (q *queryT) SetBatchSize(size uint) Query {
 }
 
 func (q *queryT) Find() error {
-	q.op = otQuery
-	if b, err := defaultClient.doRequest(q); err != nil {
+	fq := q.Clone().(*queryT)
+	fq.op = otQuery
+	if reflect.Indirect(reflect.ValueOf(q.inst)).Kind() == reflect.Slice {
+		_, err := fq.stream(q.inst)
+		return err
+	}
+
+	if b, err := defaultClient.doRequest(fq); err != nil {
 		return err
 	} else {
 		return handleResponse(b, q.inst)
 	}
 }
 
+func (q *queryT) Describe() (*RequestDescription, error) {
+	fq := q.Clone().(*queryT)
+	fq.op = otQuery
+	return describe(fq)
+}
+
+// Executes the query, decoding each result into dst - a pointer to a slice -
+// as it is read from the response. Returns the response's count, if present.
+func (q *queryT) stream(dst interface{}) (int64, error) {
+	body, err := defaultClient.openRequest(q)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	return handleResultStream(body, dst)
+}
+
 func (q *queryT) First() error {
-	q.op = otQuery
+	fq := q.Clone().(*queryT)
+	fq.op = otQuery
 	l := 1
-	q.limit = &l
+	fq.limit = &l
 
 	rv := reflect.ValueOf(q.inst)
 	rvi := reflect.Indirect(rv)
@@ -855,9 +1036,7 @@ func (q *queryT) First() error {
 		dv := reflect.New(reflect.SliceOf(rvi.Type()))
 		dv.Elem().Set(reflect.MakeSlice(reflect.SliceOf(rvi.Type()), 0, 1))
 
-		if b, err := defaultClient.doRequest(q); err != nil {
-			return err
-		} else if err := handleResponse(b, dv.Interface()); err != nil {
+		if _, err := fq.stream(dv.Interface()); err != nil {
 			return err
 		}
 
@@ -866,9 +1045,7 @@ func (q *queryT) First() error {
 			rv.Elem().Set(dv.Elem().Index(0))
 		}
 	} else if rvi.Kind() == reflect.Slice {
-		if b, err := defaultClient.doRequest(q); err != nil {
-			return err
-		} else if err := handleResponse(b, q.inst); err != nil {
+		if _, err := fq.stream(q.inst); err != nil {
 			return err
 		}
 	} else {
@@ -878,13 +1055,15 @@ func (q *queryT) First() error {
 }
 
 func (q *queryT) Count() (int64, error) {
+	cq := q.Clone().(*queryT)
+	cq.op = otQuery
 	l := 0
 	c := 1
-	q.limit = &l
-	q.count = &c
+	cq.limit = &l
+	cq.count = &c
 
 	var count int64
-	if b, err := defaultClient.doRequest(q); err != nil {
+	if b, err := defaultClient.doRequest(cq); err != nil {
 		return 0, err
 	} else {
 		err := handleResponse(b, &count)
@@ -892,10 +1071,194 @@ func (q *queryT) Count() (int64, error) {
 	}
 }
 
+func (q *queryT) FindAndCount() (int64, error) {
+	cq := q.Clone().(*queryT)
+	cq.op = otQuery
+	c := 1
+	cq.count = &c
+
+	return cq.stream(q.inst)
+}
+
+type pageTokenT struct {
+	Hash   string            `json:"h"`
+	Values []json.RawMessage `json:"v"`
+}
+
+func (q *queryT) Page(token string) (string, error) {
+	rv := reflect.ValueOf(q.inst)
+	rvi := reflect.Indirect(rv)
+	if rvi.Kind() != reflect.Slice {
+		return "", fmt.Errorf("expected slice, got %s", rvi.Kind())
+	}
+
+	if q.skip != nil {
+		return "", errors.New("cannot page over a query with a skip")
+	}
+
+	pq := q.Clone().(*queryT)
+	pq.op = otQuery
+	pq.orderBy = pageOrder(pq.orderBy)
+	if pq.limit == nil {
+		l := 100
+		pq.limit = &l
+	}
+
+	hash, err := pq.pageHash()
+	if err != nil {
+		return "", err
+	}
+
+	if token != "" {
+		pt, err := decodePageToken(token)
+		if err != nil {
+			return "", err
+		}
+
+		if pt.Hash != hash || len(pt.Values) != len(pq.orderBy) {
+			return "", ErrInvalidPageToken
+		}
+		pq.setPageBoundary(pt.Values)
+	}
+
+	if _, err := pq.stream(q.inst); err == ErrNoRows {
+		rvi.Set(reflect.MakeSlice(rvi.Type(), 0, 0))
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if rvi.Len() < *pq.limit {
+		return "", nil
+	}
+
+	return pq.nextPageToken(hash, reflect.Indirect(rvi.Index(rvi.Len()-1)))
+}
+
+// Returns the sort order used for paging - the query's own sort order
+// followed by objectId, which breaks ties between equal sort values
+func pageOrder(orderBy []string) []string {
+	order := make([]string, 0, len(orderBy)+1)
+	for _, o := range orderBy {
+		order = append(order, o)
+		if strings.TrimPrefix(o, "-") == "objectId" {
+			return order
+		}
+	}
+	return append(order, "objectId")
+}
+
+// Returns a digest of everything that determines the contents and order
+// of a query's pages. Page tokens carry this digest so that a token can't
+// be used with a query other than the one that issued it.
+func (q *queryT) pageHash() (string, error) {
+	is := make([]string, 0, len(q.include))
+	for k := range q.include {
+		is = append(is, k)
+	}
+	sort.Strings(is)
+
+	ks := make([]string, 0, len(q.keys))
+	for k := range q.keys {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+
+	b, err := json.Marshal(map[string]interface{}{
+		"className": q.className,
+		"where":     q.where,
+		"order":     q.orderBy,
+		"include":   is,
+		"keys":      ks,
+		"limit":     *q.limit,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// Constrain the query to results sorting after the row whose sort field
+// values are vs. For a sort order of a, -b, objectId this produces:
+//
+// a > va OR (a = va AND b < vb) OR (a = va AND b = vb AND objectId > vid)
+func (q *queryT) setPageBoundary(vs []json.RawMessage) {
+	or := make([]map[string]interface{}, 0, len(q.orderBy))
+	for i, o := range q.orderBy {
+		branch := map[string]interface{}{}
+		for j := 0; j < i; j++ {
+			branch[strings.TrimPrefix(q.orderBy[j], "-")] = vs[j]
+		}
+
+		if strings.HasPrefix(o, "-") {
+			branch[o[1:]] = map[string]interface{}{"$lt": vs[i]}
+		} else {
+			branch[o] = map[string]interface{}{"$gt": vs[i]}
+		}
+		or = append(or, branch)
+	}
+
+	if cur, ok := q.where["$or"]; ok {
+		delete(q.where, "$or")
+		and := []interface{}{
+			map[string]interface{}{"$or": cur},
+			map[string]interface{}{"$or": or},
+		}
+		if a, ok := q.where["$and"].([]interface{}); ok {
+			and = append(a, and...)
+		}
+		q.where["$and"] = and
+	} else {
+		q.where["$or"] = or
+	}
+}
+
+func (q *queryT) nextPageToken(hash string, last reflect.Value) (string, error) {
+	pt := pageTokenT{
+		Hash:   hash,
+		Values: make([]json.RawMessage, 0, len(q.orderBy)),
+	}
+	for _, o := range q.orderBy {
+		k := strings.TrimPrefix(o, "-")
+		f, ok := valueAtPath(last, strings.Split(k, "."))
+		if !ok {
+			return "", fmt.Errorf("cannot page on field %s - type has no matching field", k)
+		}
+
+		b, err := json.Marshal(encodeForRequest(f))
+		if err != nil {
+			return "", err
+		}
+		pt.Values = append(pt.Values, b)
+	}
+
+	b, err := json.Marshal(pt)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodePageToken(token string) (*pageTokenT, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	pt := pageTokenT{}
+	if err := json.Unmarshal(b, &pt); err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	return &pt, nil
+}
+
 func (q *queryT) payload() (string, error) {
 	p := url.Values{}
+	t := structType(q.inst)
 	if len(q.where) > 0 {
-		w, err := json.Marshal(q.where)
+		w, err := json.Marshal(wireConstraints(t, q.where))
 		if err != nil {
 			return "", err
 		}
@@ -915,14 +1278,21 @@ func (q *queryT) payload() (string, error) {
 	}
 
 	if len(q.orderBy) > 0 {
-		o := strings.Join(q.orderBy, ",")
-		p["order"] = []string{o}
+		os := make([]string, len(q.orderBy))
+		for i, o := range q.orderBy {
+			if strings.HasPrefix(o, "-") {
+				os[i] = "-" + wireKey(t, o[1:])
+			} else {
+				os[i] = wireKey(t, o)
+			}
+		}
+		p["order"] = []string{strings.Join(os, ",")}
 	}
 
 	if len(q.include) > 0 {
 		is := make([]string, 0, len(q.include))
 		for k := range q.include {
-			is = append(is, k)
+			is = append(is, wireKey(t, k))
 		}
 		i := strings.Join(is, ",")
 		p["include"] = []string{i}
@@ -931,7 +1301,7 @@ func (q *queryT) payload() (string, error) {
 	if len(q.keys) > 0 {
 		ks := make([]string, 0, len(q.include))
 		for k := range q.keys {
-			ks = append(ks, k)
+			ks = append(ks, wireKey(t, k))
 		}
 		k := strings.Join(ks, ",")
 		p["keys"] = []string{k}
@@ -1042,9 +1412,20 @@ func newIterator() *Iterator {
 // Returns the terminal error value of the iteration process, or nil if
 // the iteration process exited normally (or hasn't started yet)
 func (i *Iterator) Error() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	return i.err
 }
 
+// Records err as the terminal error of the iteration process, and reports it
+// on the Done channel
+func (i *Iterator) fail(err error) {
+	i.mu.Lock()
+	i.err = err
+	i.mu.Unlock()
+	i.resChan <- err
+}
+
 // Cancel interating over the current query. This is a no-op if iteration has
 // already terminated
 func (i *Iterator) Cancel() {
@@ -689,3 +689,271 @@ func TestGetQueryRepr(t *testing.T) {
 		}
 	}
 }
+
+func TestPage(t *testing.T) {
+	numRequests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		r.ParseForm()
+		if r.Form.Get("limit") != "2" {
+			t.Errorf("Did not get proper limit. Expected 2 got [%s]\n", r.Form.Get("limit"))
+		}
+		if r.Form.Get("order") != "-createdAt,objectId" {
+			t.Errorf("Did not get proper order. Expected -createdAt,objectId got [%s]\n", r.Form.Get("order"))
+		}
+		if r.Form.Get("skip") != "" {
+			t.Errorf("Page request should not have a skip. Got [%s]\n", r.Form.Get("skip"))
+		}
+
+		where := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(r.Form.Get("where")), &where); err != nil {
+			t.Errorf("unexpected error unmarshaling where: %v\n", err)
+		}
+		if where["city"] != "Chicago" {
+			t.Errorf("Page request did not preserve query constraints. Got where [%v]\n", where)
+		}
+
+		switch numRequests {
+		case 1:
+			if _, ok := where["$or"]; ok {
+				t.Errorf("First page should not have a page boundary. Got where [%v]\n", where)
+			}
+			fmt.Fprintf(w, `{"results":[{"objectId":"a","createdAt":"2014-12-19T22:22:22.123Z"},{"objectId":"b","createdAt":"2014-12-18T22:22:22.123Z"}]}`)
+		default:
+			date := map[string]interface{}{"__type": "Date", "iso": "2014-12-18T22:22:22.123Z"}
+			expected := []interface{}{
+				map[string]interface{}{"createdAt": map[string]interface{}{"$lt": date}},
+				map[string]interface{}{"createdAt": date, "objectId": map[string]interface{}{"$gt": "b"}},
+			}
+			if !reflect.DeepEqual(where["$or"], expected) {
+				t.Errorf("Page boundary different from expected. Got [%v] expected [%v]\n", where["$or"], expected)
+			}
+			fmt.Fprintf(w, `{"results":[{"objectId":"c","createdAt":"2014-12-17T22:22:22.123Z"}]}`)
+		}
+	})
+	defer teardownTestServer()
+
+	us := make([]User, 0)
+	q, err := NewQuery(&us)
+	if err != nil {
+		t.Errorf("Unexpected error creating query: %v\n", err)
+		t.FailNow()
+	}
+	q.EqualTo("city", "Chicago").OrderBy("-createdAt").Limit(2)
+
+	next, err := q.Page("")
+	if err != nil {
+		t.Errorf("Unexpected error retrieving first page: %v\n", err)
+		t.FailNow()
+	}
+	if len(us) != 2 || us[1].Id != "b" {
+		t.Errorf("First page did not populate slice. Got: %v\n", us)
+	}
+	if next == "" {
+		t.Errorf("First page did not return a token for the next page")
+		t.FailNow()
+	}
+
+	next, err = q.Page(next)
+	if err != nil {
+		t.Errorf("Unexpected error retrieving second page: %v\n", err)
+	}
+	if len(us) != 1 || us[0].Id != "c" {
+		t.Errorf("Second page did not populate slice. Got: %v\n", us)
+	}
+	if next != "" {
+		t.Errorf("Last page returned a token for the next page: %s\n", next)
+	}
+
+	if numRequests != 2 {
+		t.Errorf("Page did not execute the expected number of requests. Expected 2, got: %d\n", numRequests)
+	}
+}
+
+func TestPageRejectsTokenForDifferentQuery(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"results":[{"objectId":"a"},{"objectId":"b"}]}`)
+	})
+	defer teardownTestServer()
+
+	us := make([]User, 0)
+	q, _ := NewQuery(&us)
+	q.EqualTo("city", "Chicago").Limit(2)
+
+	next, err := q.Page("")
+	if err != nil {
+		t.Errorf("Unexpected error retrieving first page: %v\n", err)
+		t.FailNow()
+	}
+
+	q.EqualTo("city", "Boston")
+	if _, err := q.Page(next); err != ErrInvalidPageToken {
+		t.Errorf("Page should reject a token issued for a different query. Got: %v\n", err)
+	}
+
+	if _, err := q.Page("not a token"); err != ErrInvalidPageToken {
+		t.Errorf("Page should reject a malformed token. Got: %v\n", err)
+	}
+}
+
+func TestFindAndCount(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("count") != "1" {
+			t.Errorf("count was not 1. got [%v]\n", r.Form.Get("count"))
+		}
+		if r.Form.Get("limit") != "2" {
+			t.Errorf("limit was not 2. got [%v]\n", r.Form.Get("limit"))
+		}
+		fmt.Fprintf(w, `{"results":[{"objectId":"123"},{"objectId":"abc"}],"count":73}`)
+	})
+	defer teardownTestServer()
+
+	us := make([]User, 0)
+	q, err := NewQuery(&us)
+	if err != nil {
+		t.Errorf("Unexpected error creating query: %v\n", err)
+		t.FailNow()
+	}
+
+	q.EqualTo("city", "Chicago").Limit(2)
+	cnt, err := q.FindAndCount()
+	if err != nil {
+		t.Errorf("Error running query: %v\n", err)
+	}
+
+	if cnt != 73 {
+		t.Errorf("FindAndCount returned incorrect count. Got [%d] expected [%d]\n", cnt, 73)
+	}
+
+	if len(us) != 2 || us[0].Id != "123" || us[1].Id != "abc" {
+		t.Errorf("FindAndCount did not populate results. Got: %v\n", us)
+	}
+
+	qt := q.(*queryT)
+	if qt.count != nil || qt.limit == nil || *qt.limit != 2 {
+		t.Errorf("FindAndCount modified the query")
+	}
+}
+
+func TestQueryReusableAfterExecution(t *testing.T) {
+	var expectedLimit, expectedCount, expectedOrder string
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if v := r.Form.Get("limit"); v != expectedLimit {
+			t.Errorf("limit was incorrect. got [%v] expected [%v]\n", v, expectedLimit)
+		}
+		if v := r.Form.Get("count"); v != expectedCount {
+			t.Errorf("count was incorrect. got [%v] expected [%v]\n", v, expectedCount)
+		}
+		if v := r.Form.Get("order"); v != expectedOrder {
+			t.Errorf("order was incorrect. got [%v] expected [%v]\n", v, expectedOrder)
+		}
+		if v := r.Form.Get("where"); v != `{"city":"Chicago"}` {
+			t.Errorf("where was incorrect. got [%v]\n", v)
+		}
+		if r.Form.Get("count") == "1" {
+			fmt.Fprintf(w, `{"results":[],"count":1}`)
+		} else {
+			fmt.Fprintf(w, `{"results":[{"objectId":"123"}]}`)
+		}
+	})
+	defer teardownTestServer()
+
+	u := User{}
+	q, _ := NewQuery(&u)
+	q.EqualTo("city", "Chicago")
+
+	expectedLimit, expectedCount, expectedOrder = "1", "", ""
+	if err := q.First(); err != nil {
+		t.Errorf("Error running First: %v\n", err)
+	}
+
+	expectedLimit, expectedCount, expectedOrder = "0", "1", ""
+	if _, err := q.Count(); err != nil {
+		t.Errorf("Error running Count: %v\n", err)
+	}
+
+	expectedLimit, expectedCount, expectedOrder = "100", "", "objectId"
+	rc := make(chan *User)
+	it, err := q.Each(rc)
+	if err != nil {
+		t.Errorf("Error running Each: %v\n", err)
+		t.FailNow()
+	}
+	for range rc {
+	}
+	if err := <-it.Done(); err != nil {
+		t.Errorf("Error running Each: %v\n", err)
+	}
+
+	u = User{}
+	expectedLimit, expectedCount, expectedOrder = "1", "", ""
+	if err := q.First(); err != nil {
+		t.Errorf("Error running First: %v\n", err)
+	}
+	if u.Id != "123" {
+		t.Errorf("First did not populate result. Got: %v\n", u.Id)
+	}
+}
+
+func TestCloneCopiesConstraints(t *testing.T) {
+	q, _ := NewQuery(&User{})
+	q.GreaterThan("age", 30)
+
+	q2 := q.Clone()
+	q2.LessThan("age", 40).OrderBy("-age")
+
+	if _, ok := q.(*queryT).where["age"].(map[string]interface{})["$lt"]; ok {
+		t.Errorf("constraint added to clone modified the original query")
+	}
+
+	if len(q.(*queryT).orderBy) != 0 {
+		t.Errorf("sort order set on clone modified the original query")
+	}
+}
+
+func TestFindStreamsResults(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"count":3,"ignored":{"a":[1,2,{"b":"c"}]},"results":[{"objectId":"a","tags":["x","y"]},{"objectId":"b"},{"objectId":"c"}]}`)
+	})
+	defer teardownTestServer()
+
+	type Tagged struct {
+		Base
+		Tags []string
+	}
+
+	ts := make([]*Tagged, 0)
+	q, _ := NewQuery(&ts)
+	cnt, err := q.FindAndCount()
+	if err != nil {
+		t.Errorf("Error running query: %v\n", err)
+		t.FailNow()
+	}
+
+	if cnt != 3 {
+		t.Errorf("FindAndCount returned incorrect count. Got [%d] expected [%d]\n", cnt, 3)
+	}
+
+	if len(ts) != 3 || ts[2].Id != "c" || !reflect.DeepEqual(ts[0].Tags, []string{"x", "y"}) {
+		t.Errorf("Find did not populate results. Got: %v\n", ts)
+	}
+}
+
+func TestFindMalformedResponse(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"results":[{"objectId":"a"},`)
+	})
+	defer teardownTestServer()
+
+	us := make([]User, 0)
+	q, _ := NewQuery(&us)
+	if err := q.Find(); err == nil {
+		t.Errorf("Find should return an error for a truncated response")
+	}
+
+	if len(us) != 0 {
+		t.Errorf("Find should not populate results from a truncated response. Got: %v\n", us)
+	}
+}
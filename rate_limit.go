@@ -1,28 +1,233 @@
 package parse
 
-import "time"
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
 
-type limiter interface {
-	limit()
+// A RateClass groups operations that share a rate limit budget
+type RateClass int
+
+const (
+	// Queries, logins, and other requests that don't modify data
+	RateClassReads RateClass = iota
+
+	// Creates, updates, deletes, and pushes
+	RateClassWrites
+
+	// Cloud Code function calls
+	RateClassFunctions
+)
+
+// Returns the budget requests of kind k draw from
+func rateClassOf(k OperationKind) RateClass {
+	switch k {
+	case OpCreate, OpUpdate, OpDelete, OpPush:
+		return RateClassWrites
+	case OpFunction:
+		return RateClassFunctions
+	default:
+		return RateClassReads
+	}
+}
+
+// How long to back off after being rate limited when the server doesn't
+// send a Retry-After header
+const defaultRetryAfter = time.Second
+
+// A token bucket that refills at rate tokens per second, holding at most
+// burst tokens
+type tokenBucketT struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit, burst uint, now time.Time) *tokenBucketT {
+	if burst == 0 {
+		burst = 1
+	}
+	return &tokenBucketT{
+		rate:   float64(limit),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+func (b *tokenBucketT) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
 }
 
+// Returns how long until the bucket holds a token
+func (b *tokenBucketT) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Limits requests using an overall budget and a budget per class of
+// operation. Requests draw a token from each budget that applies to them.
 type rateLimiterT struct {
-	c chan time.Time
+	mu      sync.Mutex
+	all     *tokenBucketT
+	classes map[RateClass]*tokenBucketT
+	until   time.Time
+
+	// Closed and replaced whenever the limiter is reconfigured, waking any
+	// blocked requests so they observe the new limits
+	changed chan struct{}
+
+	// Return the current time, and wait for d to pass or for wake to be
+	// closed. Replaced in tests.
+	now   func() time.Time
+	sleep func(d time.Duration, wake <-chan struct{})
+}
+
+func newRateLimiter() *rateLimiterT {
+	return &rateLimiterT{
+		classes: map[RateClass]*tokenBucketT{},
+		changed: make(chan struct{}),
+		now:     time.Now,
+		sleep:   sleepOrWake,
+	}
 }
 
-func newRateLimiter(limit, burst uint) *rateLimiterT {
-	r := &rateLimiterT{
-		c: make(chan time.Time, burst),
+// Waits for d to pass, or for wake to be closed
+func sleepOrWake(d time.Duration, wake <-chan struct{}) {
+	t := time.NewTimer(d)
+	select {
+	case <-t.C:
+	case <-wake:
+		t.Stop()
 	}
-	go func() {
-		for t := range time.Tick(time.Second / time.Duration(limit)) {
-			r.c <- t
+}
+
+func (l *rateLimiterT) limit(k OperationKind) {
+	class := rateClassOf(k)
+	for {
+		l.mu.Lock()
+		d := l.reserve(l.now(), class)
+		changed := l.changed
+		l.mu.Unlock()
+
+		if d <= 0 {
+			return
 		}
-	}()
+		l.sleep(d, changed)
+	}
+}
+
+// Takes a token from each budget applying to class, returning 0. If any
+// budget is exhausted, or the limiter is backing off, no tokens are taken
+// and the time to wait before trying again is returned. Must be called with
+// l.mu held.
+func (l *rateLimiterT) reserve(now time.Time, class RateClass) time.Duration {
+	if now.Before(l.until) {
+		return l.until.Sub(now)
+	}
 
-	return r
+	buckets := make([]*tokenBucketT, 0, 2)
+	if l.all != nil {
+		buckets = append(buckets, l.all)
+	}
+	if b, ok := l.classes[class]; ok {
+		buckets = append(buckets, b)
+	}
+
+	var d time.Duration
+	for _, b := range buckets {
+		b.refill(now)
+		if w := b.wait(); w > d {
+			d = w
+		}
+	}
+	if d > 0 {
+		return d
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return 0
+}
+
+func (l *rateLimiterT) backoff(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := l.now().Add(d); until.After(l.until) {
+		l.until = until
+	}
+}
+
+// Replaces the overall budget. A limit of 0 removes it.
+func (l *rateLimiterT) setLimit(limit, burst uint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit == 0 {
+		l.all = nil
+	} else {
+		l.all = newTokenBucket(limit, burst, l.now())
+	}
+	l.reconfigured()
+}
+
+// Replaces the budget for class. A limit of 0 removes it.
+func (l *rateLimiterT) setClassLimit(class RateClass, limit, burst uint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit == 0 {
+		delete(l.classes, class)
+	} else {
+		l.classes[class] = newTokenBucket(limit, burst, l.now())
+	}
+	l.reconfigured()
+}
+
+// Removes all budgets and any backoff, releasing blocked requests
+func (l *rateLimiterT) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.all = nil
+	l.classes = map[RateClass]*tokenBucketT{}
+	l.until = time.Time{}
+	l.reconfigured()
 }
 
-func (l *rateLimiterT) limit() {
-	<-l.c
+// Must be called with l.mu held
+func (l *rateLimiterT) reconfigured() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Returns how long the server asked us to wait before sending another
+// request. Retry-After may be a number of seconds or an HTTP date.
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return defaultRetryAfter
+	}
+
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return defaultRetryAfter
 }
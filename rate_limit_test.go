@@ -0,0 +1,159 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A clock for rate limiter tests. Sleeping advances the clock immediately,
+// unless the clock is paused, in which case sleepers wait to be woken.
+type fakeClockT struct {
+	mu     sync.Mutex
+	t      time.Time
+	slept  time.Duration
+	paused bool
+}
+
+func useFakeClock(l *rateLimiterT) *fakeClockT {
+	c := &fakeClockT{t: time.Date(2015, 4, 1, 0, 0, 0, 0, time.UTC)}
+	l.now = c.now
+	l.sleep = c.sleep
+	return c
+}
+
+func (c *fakeClockT) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClockT) sleep(d time.Duration, wake <-chan struct{}) {
+	c.mu.Lock()
+	if c.paused {
+		c.mu.Unlock()
+		<-wake
+		return
+	}
+	c.t = c.t.Add(d)
+	c.slept += d
+	c.mu.Unlock()
+}
+
+func (c *fakeClockT) sleptFor() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slept
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	l := newRateLimiter()
+	c := useFakeClock(l)
+	l.setLimit(10, 3)
+
+	for i := 0; i < 3; i++ {
+		l.limit(OpQuery)
+	}
+	if d := c.sleptFor(); d != 0 {
+		t.Errorf("burst requests should not block. Waited %v\n", d)
+	}
+
+	l.limit(OpQuery)
+	if d := c.sleptFor(); d != 100*time.Millisecond {
+		t.Errorf("request exceeding burst should wait for a token. Waited %v\n", d)
+	}
+}
+
+func TestRateLimiterClasses(t *testing.T) {
+	l := newRateLimiter()
+	c := useFakeClock(l)
+	l.setClassLimit(RateClassWrites, 1, 1)
+
+	l.limit(OpCreate)
+	for i := 0; i < 10; i++ {
+		l.limit(OpQuery)
+		l.limit(OpFunction)
+	}
+	if d := c.sleptFor(); d != 0 {
+		t.Errorf("write budget should not limit reads or functions. Waited %v\n", d)
+	}
+
+	c.paused = true
+	done := make(chan struct{})
+	go func() {
+		l.limit(OpUpdate)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Errorf("write should have been blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Removing the budget releases the blocked request
+	l.setClassLimit(RateClassWrites, 0, 0)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Errorf("write should have been released when the limit was removed")
+	}
+}
+
+func TestSetRateLimitZero(t *testing.T) {
+	if err := SetRateLimit(0, 0); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+	defaultClient.limiter.limit(OpQuery)
+}
+
+func TestRateLimiterBacksOff(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(w, `{"code":155,"error":"request limit exceeded"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"results":[]}`)
+	})
+	defer teardownTestServer()
+	defer StopRateLimit()
+
+	c := useFakeClock(defaultClient.limiter)
+
+	us := make([]User, 0)
+	q, _ := NewQuery(&us)
+	if err := q.Find(); !errors.Is(err, ErrRequestLimitExceeded) {
+		t.Errorf("expected request limit exceeded error. Got %v\n", err)
+	}
+
+	if err := q.Find(); err != nil && err != ErrNoRows {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+	if d := c.sleptFor(); d != time.Second {
+		t.Errorf("request should have waited for Retry-After. Waited %v\n", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	h := http.Header{}
+	if d := retryAfter(h); d != defaultRetryAfter {
+		t.Errorf("expected default retry after. Got %v\n", d)
+	}
+
+	h.Set("Retry-After", "3")
+	if d := retryAfter(h); d != 3*time.Second {
+		t.Errorf("expected 3s. Got %v\n", d)
+	}
+
+	h.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if d := retryAfter(h); d < 58*time.Second || d > time.Minute {
+		t.Errorf("expected about a minute. Got %v\n", d)
+	}
+}
@@ -2,6 +2,7 @@ package parse
 
 import (
 	"compress/gzip"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,19 +16,52 @@ import (
 )
 
 const (
-	AppIdHeader        = "X-Parse-Application-Id"
-	RestKeyHeader      = "X-Parse-REST-API-Key"
-	MasterKeyHeader    = "X-Parse-Master-Key"
-	SessionTokenHeader = "X-Parse-Session-Token"
-	UserAgentHeader    = "User-Agent"
+	AppIdHeader            = "X-Parse-Application-Id"
+	RestKeyHeader          = "X-Parse-REST-API-Key"
+	MasterKeyHeader        = "X-Parse-Master-Key"
+	SessionTokenHeader     = "X-Parse-Session-Token"
+	UserAgentHeader        = "User-Agent"
+	ClientKeyHeader        = "X-Parse-Client-Key"
+	JavascriptKeyHeader    = "X-Parse-Javascript-Key"
+	InstallationIdHeader   = "X-Parse-Installation-Id"
+	ClientVersionHeader    = "X-Parse-Client-Version"
+	RevocableSessionHeader = "X-Parse-Revocable-Session"
+	MaintenanceKeyHeader   = "X-Parse-Maintenance-Key"
 )
 
+// Returned when a request that modifies data would be sent with the
+// read-only master key
+var ErrReadOnlyMasterKey = errors.New("the read-only master key can not be used for writes")
+
+// Returned when a request asks to be sent with the read-only master key or
+// the maintenance key, but that key has not been configured. The master key
+// is never sent in its place.
+var (
+	ErrNoReadOnlyMasterKey = errors.New("the read-only master key has not been set - call parse.SetReadOnlyMasterKey")
+	ErrNoMaintenanceKey    = errors.New("the maintenance key has not been set - call parse.SetMaintenanceKey")
+)
+
+// The privileged key a request asks to be sent with
+type keyT int
+
+const (
+	// The master key, if the request uses the master key
+	keyMaster keyT = iota
+	keyReadOnlyMaster
+	keyMaintenance
+)
+
+// Implemented by requests that may ask for a key other than the master key
+type iPrivilegedKey interface {
+	privilegedKey() keyT
+}
+
 var ParseScheme string = "https"
 var ParsePath string = "1"
 var parseHost string = "api.parse.com"
 
-var fieldNameCache map[reflect.Type]map[string]string = make(map[reflect.Type]map[string]string)
-var fieldCache = make(map[reflect.Type]reflect.StructField)
+var timeType = reflect.TypeOf(time.Time{})
+var dateType = reflect.TypeOf(Date{})
 
 type requestT interface {
 	method() string
@@ -38,27 +72,36 @@ type requestT interface {
 	contentType() string
 }
 
-type ParseError interface {
-	error
-	Code() int
-	Message() string
+// Implemented by requests that may carry headers of their own
+type iRequestHeaders interface {
+	requestHeaders() http.Header
 }
 
-type parseErrorT struct {
-	ErrorCode    int    `json:"code" parse:"code"`
-	ErrorMessage string `json:"error" parse:"error"`
-}
+// Customizes a single request sent to Parse by an operation that has no
+// request builder, such as Login, Create, Delete, or CallFunction
+type RequestOption func(h http.Header)
 
-func (e *parseErrorT) Error() string {
-	return fmt.Sprintf("error %d - %s", e.ErrorCode, e.ErrorMessage)
+// Set the header k to v on a single request, replacing any value set by
+// default. For example, to log in as a specific device:
+//
+//	parse.Login(username, password, nil, parse.WithHeader(parse.InstallationIdHeader, id))
+func WithHeader(k, v string) RequestOption {
+	return func(h http.Header) {
+		h.Set(k, v)
+	}
 }
 
-func (e *parseErrorT) Code() int {
-	return e.ErrorCode
-}
+// Returns the headers set by opts, or nil if opts is empty
+func optionHeaders(opts []RequestOption) http.Header {
+	if len(opts) == 0 {
+		return nil
+	}
 
-func (e *parseErrorT) Message() string {
-	return e.ErrorMessage
+	h := http.Header{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 type clientT struct {
@@ -66,10 +109,27 @@ type clientT struct {
 	restKey   string
 	masterKey string
 
+	readOnlyMasterKey string
+	maintenanceKey    string
+
+	clientKey        string
+	javascriptKey    string
+	installationId   string
+	clientVersion    string
+	revocableSession bool
+
 	userAgent  string
 	httpClient *http.Client
 
-	limiter limiter
+	limiter *rateLimiterT
+
+	middleware []Middleware
+
+	logger    Logger
+	logBodies bool
+
+	// Called when the server reports that a session's token is invalid
+	invalidSessionHandler func(s Session, err error)
 }
 
 var defaultClient *clientT
@@ -82,6 +142,7 @@ func Initialize(appId, restKey, masterKey string) {
 		masterKey:  masterKey,
 		userAgent:  "github.com/kylemcc/parse",
 		httpClient: &http.Client{},
+		limiter:    newRateLimiter(),
 	}
 }
 
@@ -126,13 +187,135 @@ func SetUserAgent(ua string) error {
 //
 // If this option is set, this library will restrict calling code to
 // a maximum number of requests per second. Requests exceeding this limit
-// will block for the appropriate period of time.
+// will block for the appropriate period of time. A limit of 0 removes the
+// limit. Calling this function again replaces the previous limit, and
+// requests blocked by it are re-evaluated against the new one.
 func SetRateLimit(limit, burst uint) error {
 	if defaultClient == nil {
-		return errors.New("parse.Initialize must be called before parse.SetHTTPTimeout")
+		return errors.New("parse.Initialize must be called before parse.SetRateLimit")
+	}
+
+	defaultClient.limiter.setLimit(limit, burst)
+	return nil
+}
+
+// Set the maximum number of requests per second for a class of operations,
+// with an optional burst rate. Class limits apply in addition to the limit
+// set by parse.SetRateLimit. A limit of 0 removes the limit for the class.
+//
+// Returns an error if called before parse.Initialize
+func SetClassRateLimit(class RateClass, limit, burst uint) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetClassRateLimit")
+	}
+
+	defaultClient.limiter.setClassLimit(class, limit, burst)
+	return nil
+}
+
+// Remove all rate limits, and stop backing off after being rate limited.
+// Any requests blocked by the rate limiter are released.
+//
+// Returns an error if called before parse.Initialize
+func StopRateLimit() error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.StopRateLimit")
+	}
+
+	defaultClient.limiter.stop()
+	return nil
+}
+
+// Set the read-only master key. Requests using the master key are sent with
+// the read-only master key instead if no master key was provided to
+// parse.Initialize, or if they ask for it explicitly (e.g. with
+// Query.UseReadOnlyMasterKey). Requests that modify data are refused rather
+// than sent with the read-only master key.
+//
+// Returns an error if called before parse.Initialize
+func SetReadOnlyMasterKey(k string) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetReadOnlyMasterKey")
+	}
+
+	defaultClient.readOnlyMasterKey = k
+	return nil
+}
+
+// Set the maintenance key, used by requests that ask for it (e.g. with
+// Query.UseMaintenanceKey)
+//
+// Returns an error if called before parse.Initialize
+func SetMaintenanceKey(k string) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetMaintenanceKey")
+	}
+
+	defaultClient.maintenanceKey = k
+	return nil
+}
+
+// Set the client key sent with each request, for servers configured to
+// accept client keys
+//
+// Returns an error if called before parse.Initialize
+func SetClientKey(k string) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetClientKey")
+	}
+
+	defaultClient.clientKey = k
+	return nil
+}
+
+// Set the JavaScript key sent with each request, for servers configured to
+// accept JavaScript keys
+//
+// Returns an error if called before parse.Initialize
+func SetJavascriptKey(k string) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetJavascriptKey")
+	}
+
+	defaultClient.javascriptKey = k
+	return nil
+}
+
+// Set the installation id sent with each request. Sessions created by
+// logging in are associated with this installation.
+//
+// Returns an error if called before parse.Initialize
+func SetInstallationId(id string) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetInstallationId")
+	}
+
+	defaultClient.installationId = id
+	return nil
+}
+
+// Set the client version sent with each request, e.g. "i1.17.3"
+//
+// Returns an error if called before parse.Initialize
+func SetClientVersion(v string) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetClientVersion")
+	}
+
+	defaultClient.clientVersion = v
+	return nil
+}
+
+// Request revocable sessions when logging in or signing up, for servers
+// that still issue legacy session tokens by default
+//
+// Returns an error if called before parse.Initialize
+func SetRevocableSession(b bool) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetRevocableSession")
 	}
 
-	defaultClient.limiter = newRateLimiter(limit, burst)
+	defaultClient.revocableSession = b
 	return nil
 }
 
@@ -145,7 +328,112 @@ func SetHTTPClient(c *http.Client) error {
 	return nil
 }
 
+// Install middleware to be called for every request sent to Parse,
+// replacing any previously installed. Middleware is called in the order
+// provided, so the first is outermost and sees the request first and
+// the response last. Calling SetMiddleware with no arguments removes
+// all middleware.
+//
+// Returns an error if called before parse.Initialize
+func SetMiddleware(mw ...Middleware) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetMiddleware")
+	}
+
+	defaultClient.middleware = append([]Middleware(nil), mw...)
+	return nil
+}
+
 func (c *clientT) doRequest(op requestT) ([]byte, error) {
+	body, err := c.openRequest(op)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+// The body of a successful response, decompressed if necessary
+type responseBodyT struct {
+	io.Reader
+	body io.Closer
+}
+
+func (r *responseBodyT) Close() error {
+	return r.body.Close()
+}
+
+// Sends the request represented by op and returns the body of the response,
+// which the caller must close. If the response is an error, the body is
+// consumed and a ParseError is returned.
+func (c *clientT) openRequest(op requestT) (io.ReadCloser, error) {
+	s := op.session()
+	if s != nil && s.isInvalidated() {
+		return nil, ErrSessionInvalidated
+	}
+
+	req, err := c.newRequest(op)
+	if err != nil {
+		return nil, err
+	}
+	method, ep := req.Method, req.URL.String()
+
+	info := newRequestInfo(op)
+	c.limiter.limit(info.Kind)
+
+	resp, err := c.roundTrip()(info, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip":
+		if r, err := gzip.NewReader(resp.Body); err != nil {
+			resp.Body.Close()
+			return nil, err
+		} else {
+			reader = r
+		}
+	default:
+		reader = resp.Body
+	}
+
+	// Error formats are consistent. If the response is an error,
+	// return a ParseError
+	if !(resp.StatusCode >= 200 && resp.StatusCode < 300) {
+		defer resp.Body.Close()
+		respBody, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		perr := newParseError(resp.StatusCode, method, ep, respBody)
+
+		// When rate limited, hold back further requests until the server
+		// is ready to accept them again
+		if resp.StatusCode == http.StatusTooManyRequests || perr.ErrorCode == CodeRequestLimitExceeded {
+			c.limiter.backoff(retryAfter(resp.Header))
+		}
+
+		if s != nil && perr.ErrorCode == CodeInvalidSessionToken {
+			s.invalidate()
+
+			// A logout the caller asked for should not prompt them to log
+			// in again
+			if _, ok := op.(*logoutT); !ok && c.invalidSessionHandler != nil {
+				c.invalidSessionHandler(s, perr)
+			}
+		}
+		return nil, perr
+	}
+
+	return &responseBodyT{Reader: reader, body: resp.Body}, nil
+}
+
+// Builds the HTTP request represented by op, with the headers required by
+// Parse
+func (c *clientT) newRequest(op requestT) (*http.Request, error) {
 	ep, err := op.endpoint()
 	if err != nil {
 		return nil, err
@@ -168,58 +456,72 @@ func (c *clientT) doRequest(op requestT) ([]byte, error) {
 
 	req.Header.Add(UserAgentHeader, defaultClient.userAgent)
 	req.Header.Add(AppIdHeader, defaultClient.appId)
-	if op.useMasterKey() && c.masterKey != "" && op.session() == nil {
+	key := keyMaster
+	if pk, ok := op.(iPrivilegedKey); ok {
+		key = pk.privilegedKey()
+	}
+
+	// A request that asks for a restricted key must never fall back to the
+	// master key
+	if op.useMasterKey() && op.session() == nil {
+		if key == keyReadOnlyMaster && c.readOnlyMasterKey == "" {
+			return nil, ErrNoReadOnlyMasterKey
+		} else if key == keyMaintenance && c.maintenanceKey == "" {
+			return nil, ErrNoMaintenanceKey
+		}
+	}
+
+	if key == keyMaster && c.masterKey == "" {
+		key = keyReadOnlyMaster
+	}
+
+	if op.useMasterKey() && op.session() == nil && key == keyMaintenance {
+		req.Header.Add(MaintenanceKeyHeader, c.maintenanceKey)
+	} else if op.useMasterKey() && op.session() == nil && key == keyReadOnlyMaster && c.readOnlyMasterKey != "" {
+		if rateClassOf(newRequestInfo(op).Kind) == RateClassWrites {
+			return nil, ErrReadOnlyMasterKey
+		}
+		req.Header.Add(MasterKeyHeader, c.readOnlyMasterKey)
+	} else if op.useMasterKey() && c.masterKey != "" && op.session() == nil {
 		req.Header.Add(MasterKeyHeader, c.masterKey)
 	} else {
-		req.Header.Add(RestKeyHeader, c.restKey)
+		if c.restKey != "" || (c.clientKey == "" && c.javascriptKey == "") {
+			req.Header.Add(RestKeyHeader, c.restKey)
+		}
+		if c.clientKey != "" {
+			req.Header.Add(ClientKeyHeader, c.clientKey)
+		}
+		if c.javascriptKey != "" {
+			req.Header.Add(JavascriptKeyHeader, c.javascriptKey)
+		}
 		if s := op.session(); s != nil {
 			req.Header.Add(SessionTokenHeader, s.sessionToken)
 		}
 	}
 
-	if c := op.contentType(); c != "" {
-		req.Header.Add("Content-Type", op.contentType())
+	if c.installationId != "" {
+		req.Header.Add(InstallationIdHeader, c.installationId)
 	}
-	req.Header.Add("Accept-Encoding", "gzip")
-
-	if c.limiter != nil {
-		c.limiter.limit()
-	}
-
-	resp, err := defaultClient.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	if c.clientVersion != "" {
+		req.Header.Add(ClientVersionHeader, c.clientVersion)
 	}
-
-	defer resp.Body.Close()
-	var reader io.ReadCloser
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		if r, err := gzip.NewReader(resp.Body); err != nil {
-			return nil, err
-		} else {
-			reader = r
-		}
-	default:
-		reader = resp.Body
+	if c.revocableSession {
+		req.Header.Add(RevocableSessionHeader, "1")
 	}
 
-	respBody, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return nil, err
+	if c := op.contentType(); c != "" {
+		req.Header.Add("Content-Type", op.contentType())
 	}
+	req.Header.Add("Accept-Encoding", "gzip")
 
-	// Error formats are consistent. If the response is an error,
-	// return a ParseError
-	if !(resp.StatusCode >= 200 && resp.StatusCode < 300) {
-		ret := parseErrorT{}
-		if err := json.Unmarshal(respBody, &ret); err != nil {
-			return nil, err
+	// Headers set on the request itself take precedence
+	if h, ok := op.(iRequestHeaders); ok {
+		for k, v := range h.requestHeaders() {
+			req.Header[k] = append([]string(nil), v...)
 		}
-		return nil, &ret
 	}
 
-	return respBody, nil
+	return req, nil
 }
 
 func handleResponse(body []byte, dst interface{}) error {
@@ -247,6 +549,199 @@ func handleResponse(body []byte, dst interface{}) error {
 	}
 }
 
+// Decodes a query response from r, calling fn to decode each element of the
+// results array from dec as it is read, so that a page of results is never
+// held in memory in its entirety. fn must consume exactly one value from dec.
+// Returns the value of the response's count, if present.
+//
+// If fn returns an error, decoding stops and that error is returned.
+func decodeResultStream(r io.Reader, fn func(dec *json.Decoder) error) (count int64, err error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return 0, err
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return 0, err
+		}
+
+		switch t {
+		case "results":
+			if err := expectDelim(dec, '['); err != nil {
+				return 0, err
+			}
+			for dec.More() {
+				if err := fn(dec); err != nil {
+					return 0, err
+				}
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return 0, err
+			}
+		case "count":
+			if err := dec.Decode(&count); err != nil {
+				return 0, err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return count, expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, d json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != d {
+		return fmt.Errorf("malformed response - expected %s, got %v", d, t)
+	}
+	return nil
+}
+
+// Decodes the next value from dec into the value pointed to by dst. Objects
+// are decoded directly into struct types, and anything else is decoded
+// generically and converted with populateValue.
+func decodeResult(dec *json.Decoder, dst reflect.Value) error {
+	if t := dst.Elem().Type(); t.Kind() == reflect.Struct && t != timeType && t != dateType {
+		return decodeObject(dec, dst.Elem())
+	}
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	return populateValue(dst.Interface(), v)
+}
+
+// Decodes the next value from dec, which must be an object, into the
+// addressable struct value dst. Fields of plain JSON types are decoded
+// directly. Fields holding Parse types, such as Dates and Pointers, and keys
+// with no matching field are decoded generically and populated as
+// populateValue would.
+func decodeObject(dec *json.Decoder, dst reflect.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("error populating struct: %v", r)
+			}
+		}
+	}()
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	codec := codecFor(dst.Type())
+	var extra reflect.Value
+	if codec.extra != nil {
+		extra = dst.FieldByIndex(codec.extra)
+		if extra.IsNil() {
+			extra.Set(reflect.ValueOf(make(map[string]interface{})))
+		}
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		k, _ := t.(string)
+		if k == "__type" || k == "className" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fp := codec.field(k)
+		if fp != nil && fp.direct {
+			if err := dec.Decode(dst.FieldByIndex(fp.index).Addr().Interface()); err != nil {
+				return fmt.Errorf("can not set field %s - %s", fp.name, err)
+			}
+			continue
+		}
+
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+
+		if fp != nil {
+			if err := populateField(dst, fp, v); err != nil {
+				return err
+			}
+		} else if extra.IsValid() && extra.Kind() == reflect.Map {
+			extra.SetMapIndex(reflect.ValueOf(firstToUpper(k)), reflect.ValueOf(v))
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+
+	if codec.tracker != nil {
+		tracker := dst.FieldByIndex(codec.tracker).Addr().Interface().(*ChangeTracker)
+		tracker.snapshot = codec.snapshot(dst)
+	}
+	return nil
+}
+
+// Decodes a query response from r, populating dst - a pointer to a slice -
+// with each result as it is read. Returns the value of the response's count,
+// if present, or ErrNoRows if the response contained no results.
+func handleResultStream(r io.Reader, dst interface{}) (int64, error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return 0, errors.New("v must be a non-nil pointer")
+	}
+
+	st := rv.Elem().Type()
+	if st.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("expected slice, got %s", st.Kind())
+	}
+
+	et := st.Elem()
+	s := reflect.MakeSlice(st, 0, 0)
+	count, err := decodeResultStream(r, func(dec *json.Decoder) error {
+		var newV reflect.Value
+		if et.Kind() == reflect.Ptr {
+			newV = reflect.New(et.Elem())
+		} else {
+			newV = reflect.New(et)
+		}
+
+		if err := decodeResult(dec, newV); err != nil {
+			return err
+		}
+
+		if et.Kind() == reflect.Ptr {
+			s = reflect.Append(s, newV)
+		} else {
+			s = reflect.Append(s, newV.Elem())
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	} else if s.Len() == 0 {
+		return count, ErrNoRows
+	}
+
+	rv.Elem().Set(s)
+	return count, nil
+}
+
 func getFields(t reflect.Type) []reflect.StructField {
 	fields := make([]reflect.StructField, 0)
 
@@ -277,32 +772,34 @@ func getFields(t reflect.Type) []reflect.StructField {
 	return fields
 }
 
-func getFieldNameMap(v reflect.Value) map[string]string {
-	// Get the actual type we care about. Indirect any pointers, and handle
-	ind := reflect.Indirect(v)
-	t := ind.Type()
-	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
-		t = t.Elem()
-		if t.Kind() == reflect.Ptr {
-			t = t.Elem()
-		}
+// Populates the field of the struct value v described by fp from src. Nil
+// values are ignored.
+func populateField(v reflect.Value, fp *fieldPlan, src interface{}) error {
+	if src == nil {
+		return nil
 	}
 
-	if f, ok := fieldNameCache[t]; ok {
-		return f
+	f := v.FieldByIndex(fp.index)
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			f.Set(reflect.New(f.Type().Elem()))
+		}
 	}
 
-	fields := getFields(t)
-
-	fieldMap := make(map[string]string)
-	for _, f := range fields {
-		if name, _ := parseTag(f.Tag.Get("parse")); name != "" && name != "-" {
-			fieldMap[name] = f.Name
+	fi := reflect.Indirect(f)
+	if fi.CanSet() {
+		var err error
+		if f.Kind() == reflect.Ptr {
+			err = populateValue(f.Interface(), src)
+		} else {
+			fptr := f.Addr()
+			err = populateValue(fptr.Interface(), src)
+		}
+		if err != nil {
+			return fmt.Errorf("can not set field %s - %s", fp.name, err)
 		}
 	}
-
-	fieldNameCache[t] = fieldMap
-	return fieldMap
+	return nil
 }
 
 func populateValue(dst interface{}, src interface{}) (err error) {
@@ -355,7 +852,7 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 			return fmt.Errorf("expected slice, got %s", sv.Kind())
 		}
 	case reflect.Struct: // TODO: Handle other Parse object types ?
-		if dvi.Type() == reflect.TypeOf(time.Time{}) || dvi.Type() == reflect.TypeOf(Date{}) {
+		if dvi.Type() == timeType || dvi.Type() == dateType {
 			if s, ok := src.(string); ok {
 				if t, err := parseTime(s); err != nil {
 					return err
@@ -385,11 +882,18 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 			} else {
 				return fmt.Errorf("expected string or Date type, got %s", sv.Type())
 			}
+		} else if svi.Kind() == reflect.String && dvi.CanAddr() && reflect.PtrTo(dvi.Type()).Implements(textUnmarshalerType) {
+			return dvi.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(svi.String()))
 		} else if svi.Kind() == reflect.Map {
-			fieldNameMap := getFieldNameMap(dvi)
+			codec := codecFor(dvi.Type())
 			if m, ok := src.(map[string]interface{}); ok {
-				if f := dvi.FieldByName("Extra"); f.IsValid() && f.CanSet() && f.IsNil() {
-					f.Set(reflect.ValueOf(make(map[string]interface{})))
+				var extra reflect.Value
+				if codec.extra != nil {
+					extra = dvi.FieldByIndex(codec.extra)
+				}
+
+				if extra.IsValid() && extra.CanSet() && extra.IsNil() {
+					extra.Set(reflect.ValueOf(make(map[string]interface{})))
 				}
 
 				for k, v := range m {
@@ -397,36 +901,19 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 						continue
 					}
 
-					if nk, ok := fieldNameMap[k]; ok {
-						k = nk
-					}
-
-					k = firstToUpper(k)
-
-					if f := dvi.FieldByName(k); f.IsValid() && v != nil {
-						if f.Kind() == reflect.Ptr {
-							if f.IsNil() {
-								f.Set(reflect.New(f.Type().Elem()))
-							}
+					if fp := codec.field(k); fp != nil {
+						if err := populateField(dvi, fp, v); err != nil {
+							return err
 						}
-
-						fi := reflect.Indirect(f)
-						if fi.CanSet() {
-							var err error
-							if f.Kind() == reflect.Ptr {
-								err = populateValue(f.Interface(), v)
-							} else {
-								fptr := f.Addr()
-								err = populateValue(fptr.Interface(), v)
-							}
-							if err != nil {
-								return fmt.Errorf("can not set field %s - %s", k, err)
-							}
-						}
-					} else if f := dvi.FieldByName("Extra"); f.IsValid() && f.Kind() == reflect.Map {
-						f.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+					} else if extra.IsValid() && extra.Kind() == reflect.Map {
+						extra.SetMapIndex(reflect.ValueOf(firstToUpper(k)), reflect.ValueOf(v))
 					}
 				}
+
+				if codec.tracker != nil && dvi.CanAddr() {
+					tracker := dvi.FieldByIndex(codec.tracker).Addr().Interface().(*ChangeTracker)
+					tracker.snapshot = codec.snapshot(dvi)
+				}
 			} else {
 				return fmt.Errorf("expected map[string]interface{} got %s", sv.Type())
 			}
@@ -475,7 +962,7 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 			}
 		} else if m, ok := src.(map[string]interface{}); ok {
 			if c, ok := m["className"]; ok {
-				if t, ok := registeredTypes[c.(string)]; ok {
+				if t, ok := registeredType(c.(string)); ok {
 					tv := reflect.New(t)
 					if err := populateValue(tv.Interface(), src); err != nil {
 						return err
@@ -0,0 +1,241 @@
+package parse
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestClientHeaders(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		expected := map[string]string{
+			ClientKeyHeader:        "client_key",
+			JavascriptKeyHeader:    "js_key",
+			InstallationIdHeader:   "install_1",
+			ClientVersionHeader:    "go1.0",
+			RevocableSessionHeader: "1",
+			RestKeyHeader:          "rest_key",
+		}
+		if r.URL.Query().Get("where") != "" {
+			expected[InstallationIdHeader] = "install_2"
+		}
+
+		for k, v := range expected {
+			if h := r.Header.Get(k); h != v {
+				t.Errorf("unexpected %s header. Got [%s] expected [%s]\n", k, h, v)
+			}
+		}
+		fmt.Fprintf(w, `{"results":[{"objectId":"abc"}]}`)
+	})
+	defer teardownTestServer()
+
+	SetClientKey("client_key")
+	SetJavascriptKey("js_key")
+	SetInstallationId("install_1")
+	SetClientVersion("go1.0")
+	SetRevocableSession(true)
+	defer func() {
+		SetClientKey("")
+		SetJavascriptKey("")
+		SetInstallationId("")
+		SetClientVersion("")
+		SetRevocableSession(false)
+	}()
+
+	u := User{}
+	q, _ := NewQuery(&u)
+	if err := q.First(); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+
+	q.EqualTo("username", "kylemcc").SetHeader(InstallationIdHeader, "install_2")
+	if err := q.First(); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+}
+
+func TestRequestOptionHeaders(t *testing.T) {
+	paths := []string{}
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if h := r.Header.Get(InstallationIdHeader); h != "device_1" {
+			t.Errorf("unexpected installation id header for %s %s. Got [%s]\n", r.Method, r.URL.Path, h)
+		}
+
+		switch r.URL.Path {
+		case "/1/login", "/1/users", "/1/loginAs", "/1/users/me", "/1/verifyPassword":
+			fmt.Fprintf(w, `{"objectId":"u1","sessionToken":"r:abcd"}`)
+		case "/1/functions/hello":
+			fmt.Fprintf(w, `{"result":"hello"}`)
+		default:
+			fmt.Fprintf(w, `{"objectId":"abc"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	SetInstallationId("install_1")
+	defer SetInstallationId("")
+
+	opt := WithHeader(InstallationIdHeader, "device_1")
+	if _, err := Login("kylemcc", "secret", nil, opt); err != nil {
+		t.Errorf("unexpected error logging in: %v\n", err)
+	}
+	if _, err := LoginWith(AuthProviderApple, &AppleAuthData{Id: "a1"}, nil, opt); err != nil {
+		t.Errorf("unexpected error logging in: %v\n", err)
+	}
+	if err := Signup("kylemcc", "secret", &User{}, opt); err != nil {
+		t.Errorf("unexpected error signing up: %v\n", err)
+	}
+	if err := Create(&CustomClass{}, false, opt); err != nil {
+		t.Errorf("unexpected error creating: %v\n", err)
+	}
+	if err := Delete(&CustomClass{Base: Base{Id: "abc"}}, false, opt); err != nil {
+		t.Errorf("unexpected error deleting: %v\n", err)
+	}
+	var resp string
+	if err := CallFunction("hello", nil, &resp, opt); err != nil {
+		t.Errorf("unexpected error calling function: %v\n", err)
+	}
+
+	if _, err := LoginAs("u1", nil, opt); err != nil {
+		t.Errorf("unexpected error logging in as user: %v\n", err)
+	}
+	if _, err := Become("r:abcd", nil, opt); err != nil {
+		t.Errorf("unexpected error becoming user: %v\n", err)
+	}
+	if _, err := VerifyPassword("kylemcc", "secret", nil, opt); err != nil {
+		t.Errorf("unexpected error verifying password: %v\n", err)
+	}
+	if err := RequestPasswordReset("kylemcc@gmail.com", opt); err != nil {
+		t.Errorf("unexpected error requesting password reset: %v\n", err)
+	}
+	if err := RequestEmailVerification("kylemcc@gmail.com", opt); err != nil {
+		t.Errorf("unexpected error requesting email verification: %v\n", err)
+	}
+
+	if len(paths) != 11 {
+		t.Errorf("expected 11 requests. Got %v\n", paths)
+	}
+}
+
+func TestClientKeyWithoutRestKey(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Header[http.CanonicalHeaderKey(RestKeyHeader)]; ok {
+			t.Errorf("REST key header should not be sent when only a client key is configured")
+		}
+		if h := r.Header.Get(ClientKeyHeader); h != "client_key" {
+			t.Errorf("unexpected client key header. Got [%s]\n", h)
+		}
+		fmt.Fprintf(w, `{"objectId":"abc"}`)
+	})
+	defer teardownTestServer()
+
+	rk := defaultClient.restKey
+	defaultClient.restKey = ""
+	SetClientKey("client_key")
+	defer func() {
+		defaultClient.restKey = rk
+		SetClientKey("")
+	}()
+
+	if err := Create(&CustomClass{}, false); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+}
+
+func TestReadOnlyMasterKey(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if h := r.Header.Get(MasterKeyHeader); h != "ro_key" {
+			t.Errorf("expected read-only master key. Got [%s]\n", h)
+		}
+		fmt.Fprintf(w, `{"objectId":"abc"}`)
+	})
+	defer teardownTestServer()
+
+	SetReadOnlyMasterKey("ro_key")
+	defer SetReadOnlyMasterKey("")
+
+	// Explicitly requested while a master key is configured
+	q, _ := NewQuery(&CustomClass{})
+	if err := q.UseReadOnlyMasterKey().Get("abc"); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+
+	// Used in place of the master key when none is configured
+	mk := defaultClient.masterKey
+	defaultClient.masterKey = ""
+	defer func() { defaultClient.masterKey = mk }()
+
+	q, _ = NewQuery(&CustomClass{})
+	if err := q.UseMasterKey().Get("abc"); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+
+	if err := Create(&CustomClass{}, true); err != ErrReadOnlyMasterKey {
+		t.Errorf("expected ErrReadOnlyMasterKey from Create. Got %v\n", err)
+	}
+
+	if err := Delete(&CustomClass{}, true); err != ErrReadOnlyMasterKey {
+		t.Errorf("expected ErrReadOnlyMasterKey from Delete. Got %v\n", err)
+	}
+
+	up, _ := NewUpdate(&CustomClass{})
+	if err := up.Set("name", "x").UseMasterKey().Execute(); err != ErrReadOnlyMasterKey {
+		t.Errorf("expected ErrReadOnlyMasterKey from Update. Got %v\n", err)
+	}
+
+	if requests != 2 {
+		t.Errorf("expected only the queries to be sent. Got %d requests\n", requests)
+	}
+}
+
+func TestMaintenanceKey(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get(MaintenanceKeyHeader); h != "maintenance_key" {
+			t.Errorf("expected maintenance key. Got [%s]\n", h)
+		}
+		if h := r.Header.Get(MasterKeyHeader); h != "" {
+			t.Errorf("master key should not be sent with the maintenance key. Got [%s]\n", h)
+		}
+		fmt.Fprintf(w, `{"objectId":"abc"}`)
+	})
+	defer teardownTestServer()
+
+	SetMaintenanceKey("maintenance_key")
+	defer SetMaintenanceKey("")
+
+	q, _ := NewQuery(&CustomClass{})
+	if err := q.UseMaintenanceKey().Get("abc"); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+}
+
+func TestRestrictedKeysDoNotFallBackToMasterKey(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"objectId":"abc"}`)
+	})
+	defer teardownTestServer()
+
+	q, _ := NewQuery(&CustomClass{})
+	if err := q.UseReadOnlyMasterKey().Get("abc"); err != ErrNoReadOnlyMasterKey {
+		t.Errorf("expected ErrNoReadOnlyMasterKey. Got %v\n", err)
+	}
+
+	q, _ = NewQuery(&CustomClass{})
+	if err := q.UseMaintenanceKey().Get("abc"); err != ErrNoMaintenanceKey {
+		t.Errorf("expected ErrNoMaintenanceKey from Query. Got %v\n", err)
+	}
+
+	up, _ := NewUpdate(&CustomClass{Base: Base{Id: "abc"}})
+	if err := up.Set("name", "x").UseMaintenanceKey().Execute(); err != ErrNoMaintenanceKey {
+		t.Errorf("expected ErrNoMaintenanceKey from Update. Got %v\n", err)
+	}
+
+	if requests != 0 {
+		t.Errorf("no requests should be sent. Got %d requests\n", requests)
+	}
+}
@@ -0,0 +1,106 @@
+package parse
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// Embed ChangeTracker in a custom type to have Save send only the fields
+// that changed since the object was last fetched, created, or saved.
+// Without it, Save sends every field when updating an object.
+//
+//	type Game struct {
+//		parse.Base
+//		parse.ChangeTracker
+//		Score int
+//	}
+type ChangeTracker struct {
+	// The JSON encoding of each field, as of when the object was last
+	// populated from Parse
+	snapshot map[string]string
+}
+
+var changeTrackerType = reflect.TypeOf(ChangeTracker{})
+
+// Returns whether the object has been populated from Parse since it was
+// created, meaning changes to it can be tracked
+func (c *ChangeTracker) Tracked() bool {
+	return c.snapshot != nil
+}
+
+// Returns the JSON encoding of each field of v that is sent when saving it
+func (c *structCodec) snapshot(v reflect.Value) map[string]string {
+	s := make(map[string]string, len(c.encodeFields))
+	for k, fv := range c.encode(v) {
+		if b, err := json.Marshal(fv); err == nil {
+			s[k] = string(b)
+		}
+	}
+	return s
+}
+
+// Save the instance of the type pointed to by v to the Parse database. If v
+// has no Id, it is created. Otherwise, it is updated, sending only the
+// fields that changed if the type embeds ChangeTracker, or every field if it
+// doesn't. If useMasterKey=true, the Master Key will be used for the request.
+func Save(v interface{}, useMasterKey bool) error {
+	return save(v, useMasterKey, nil)
+}
+
+func save(v interface{}, useMasterKey bool, currentSession *sessionT) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("v must be a non-nil pointer to a struct")
+	}
+
+	rvi := rv.Elem()
+	idf := rvi.FieldByName("Id")
+	if !idf.IsValid() || idf.Kind() != reflect.String {
+		return errors.New("can not save value - type has no Id field")
+	}
+
+	if idf.String() == "" {
+		return create(v, useMasterKey, currentSession)
+	}
+
+	codec := codecFor(rvi.Type())
+	var prev map[string]string
+	if codec.tracker != nil {
+		prev = rvi.FieldByIndex(codec.tracker).Interface().(ChangeTracker).snapshot
+	}
+
+	values := map[string]updateOpT{}
+	current := codec.encode(rvi)
+	for k, fv := range current {
+		if prev != nil {
+			if b, err := json.Marshal(fv); err == nil && string(b) == prev[k] {
+				continue
+			}
+		}
+		values[k] = updateOpT{UpdateType: opSet, Value: fv}
+	}
+
+	// Fields omitted when empty that were set previously
+	for k := range prev {
+		if _, ok := current[k]; !ok {
+			values[k] = updateOpT{UpdateType: opDelete}
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	u := &updateT{
+		inst:               v,
+		values:             values,
+		shouldUseMasterKey: useMasterKey,
+		currentSession:     currentSession,
+	}
+	if b, err := defaultClient.doRequest(u); err != nil {
+		return err
+	} else {
+		return handleResponse(b, v)
+	}
+}
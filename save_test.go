@@ -0,0 +1,113 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type TrackedGame struct {
+	Base
+	ChangeTracker
+	Name  string
+	Score int
+	Tags  []string `parse:",omitempty"`
+}
+
+func TestSaveCreatesNewObjects(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/1/classes/TrackedGame" {
+			t.Errorf("unexpected request: %s %s\n", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"objectId":"g1","createdAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	g := &TrackedGame{Name: "chess", Score: 1}
+	if err := Save(g, false); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	if g.Id != "g1" || !g.Tracked() {
+		t.Errorf("object was not populated and tracked: %+v\n", g)
+	}
+}
+
+func TestSaveSendsChangedFields(t *testing.T) {
+	bodies := []map[string]interface{}{}
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			fmt.Fprintf(w, `{"objectId":"g1","name":"chess","score":1,"tags":["board"]}`)
+		case "PUT":
+			if r.URL.Path != "/1/classes/TrackedGame/g1" {
+				t.Errorf("unexpected path: %s\n", r.URL.Path)
+			}
+			body := map[string]interface{}{}
+			b, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(b, &body)
+			bodies = append(bodies, body)
+			fmt.Fprintf(w, `{"updatedAt":"2015-04-01T14:44:14.123Z"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	g := &TrackedGame{}
+	q, _ := NewQuery(g)
+	if err := q.Get("g1"); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	// Nothing changed, so nothing is sent
+	if err := Save(g, false); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+
+	g.Score = 2
+	if err := Save(g, false); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+
+	g.Tags[0] = "strategy"
+	if err := Save(g, false); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+
+	g.Tags = nil
+	if err := Save(g, false); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+
+	expected := []map[string]interface{}{
+		{"score": float64(2)},
+		{"tags": []interface{}{"strategy"}},
+		{"tags": map[string]interface{}{"__op": "Delete"}},
+	}
+	if !reflect.DeepEqual(bodies, expected) {
+		t.Errorf("unexpected bodies. Got:\n%v\nexpected:\n%v\n", bodies, expected)
+	}
+}
+
+func TestSaveUntrackedSendsAllFields(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+
+		expected := map[string]interface{}{"name": "chess", "score": float64(3)}
+		if r.Method != "PUT" || !reflect.DeepEqual(body, expected) {
+			t.Errorf("unexpected request: %s %s\n", r.Method, b)
+		}
+		fmt.Fprintf(w, `{"updatedAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	g := &TrackedGame{Base: Base{Id: "g1"}, Name: "chess", Score: 3}
+	if err := Save(g, false); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+}
@@ -1,31 +1,130 @@
 package parse
 
 import (
+	"encoding/gob"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
+	"path"
 	"reflect"
+	"sync/atomic"
 )
 
 type Session interface {
 	User() interface{}
+
+	// Set the value pointed to by dst to this session's user. dst must be
+	// a pointer to a variable of the user's type, or of a pointer to it, e.g.:
+	//
+	//	var u *MyUser
+	//	err := s.UserAs(&u)
+	UserAs(dst interface{}) error
+
+	// Re-fetch this session's user from Parse
+	Refresh() error
+
 	NewQuery(v interface{}) (Query, error)
 	NewUpdate(v interface{}) (Update, error)
-	Create(v interface{}) error
-	Delete(v interface{}) error
-	CallFunction(name string, params Params, resp interface{}) error
+	Create(v interface{}, opts ...RequestOption) error
+	Delete(v interface{}, opts ...RequestOption) error
+
+	// Create or update v as this session's user. See parse.Save
+	Save(v interface{}) error
+
+	// Refresh v as this session's user. See parse.Fetch
+	Fetch(v interface{}) error
+
+	// Refresh v as this session's user, including the objects referenced
+	// by keys. See parse.FetchWithInclude
+	FetchWithInclude(v interface{}, keys ...string) error
+
+	// Refresh v as this session's user, unless it has already been
+	// populated. See parse.FetchIfNeeded
+	FetchIfNeeded(v interface{}) error
+
+	// Refresh each object in the slice pointed to by v as this session's
+	// user. See parse.FetchAll
+	FetchAll(v interface{}) error
+	CallFunction(name string, params Params, resp interface{}, opts ...RequestOption) error
+
+	// Retrieve the Parse Config as this session's user
+	GetConfig() (Config, error)
+
+	// Create a new push notification sent as this session's user. Client
+	// push must be enabled for the app.
+	NewPushNotification() PushNotification
+
+	// Retrieve the details of this session
+	Info() (*SessionInfo, error)
+
+	// Retrieve all sessions belonging to this session's user
+	ListSessions() ([]*SessionInfo, error)
+
+	// Revoke the session of this session's user identified by id
+	RevokeSession(id string) error
+
+	// Exchange this session's legacy session token for a revocable one. Once
+	// upgraded, this session uses the new token for subsequent requests.
+	UpgradeToRevocableSession() error
+
+	// Link the account described by authData with the given provider to
+	// this session's user
+	LinkWith(provider string, authData interface{}) error
+
+	// Unlink the given provider from this session's user
+	Unlink(provider string) error
+
+	// Convert this session's anonymous user to a regular user with the
+	// given username and password
+	UpgradeAnonymousUser(username, password string) error
+
+	// Log out, revoking this session's token. Once logged out, requests
+	// made with this session fail with ErrSessionInvalidated.
+	Logout() error
+
+	// Sessions may be marshaled with encoding/json or encoding/gob, and
+	// restored with parse.RestoreSession
+	json.Marshaler
+	json.Unmarshaler
+	gob.GobEncoder
+	gob.GobDecoder
 }
 
+// Returned by requests made with a session that has been logged out, or
+// whose token the server has reported as invalid
+var ErrSessionInvalidated = errors.New("session has been logged out or invalidated")
+
 type loginRequestT struct {
 	username string
 	password string
 	s        *sessionT
-	authdata *AuthData
+	authdata map[string]interface{}
+	headers  http.Header
 }
 
 type sessionT struct {
 	user         interface{}
 	sessionToken string
+
+	// Set to 1 once the session has been logged out or invalidated
+	invalidated int32
+}
+
+// Set a function to be called when the server rejects a session's token
+// with code 209 (invalid session token), e.g. to prompt the user to log in
+// again. The session is invalidated before h is called. Pass nil to remove
+// the handler.
+//
+// Returns an error if called before parse.Initialize
+func SetInvalidSessionHandler(h func(s Session, err error)) error {
+	if defaultClient == nil {
+		return errors.New("parse.Initialize must be called before parse.SetInvalidSessionHandler")
+	}
+
+	defaultClient.invalidSessionHandler = h
+	return nil
 }
 
 // Login in as the user identified by the provided username and password.
@@ -33,7 +132,7 @@ type sessionT struct {
 // Optionally provide a custom User type to use in place of parse.User. If u is not
 // nil, it will be populated with the user's attributes, and will be accessible
 // by calling session.User().
-func Login(username, password string, u interface{}) (Session, error) {
+func Login(username, password string, u interface{}, opts ...RequestOption) (Session, error) {
 	var user interface{}
 
 	if u == nil {
@@ -45,7 +144,8 @@ func Login(username, password string, u interface{}) (Session, error) {
 	}
 
 	s := &sessionT{user: user}
-	if b, err := defaultClient.doRequest(&loginRequestT{username: username, password: password}); err != nil {
+	r := &loginRequestT{username: username, password: password, headers: optionHeaders(opts)}
+	if b, err := defaultClient.doRequest(r); err != nil {
 		return nil, err
 	} else if st, err := handleLoginResponse(b, s.user); err != nil {
 		return nil, err
@@ -56,9 +156,29 @@ func Login(username, password string, u interface{}) (Session, error) {
 	return s, nil
 }
 
-func LoginFacebook(authData *FacebookAuthData, u interface{}) (Session, error) {
+func LoginFacebook(authData *FacebookAuthData, u interface{}, opts ...RequestOption) (Session, error) {
+	return LoginWith(AuthProviderFacebook, authData, u, opts...)
+}
+
+// Log in as the user identified by userId, using the master key. The
+// returned session acts as that user, subject to their ACLs and roles.
+//
+// Returns an error if no master key is configured.
+//
+// Optionally provide a custom User type to use in place of parse.User. If u is not
+// nil, it will be populated with the user's attributes, and will be accessible
+// by calling session.User().
+func LoginAs(userId string, u interface{}, opts ...RequestOption) (Session, error) {
 	var user interface{}
 
+	if defaultClient.masterKey == "" {
+		return nil, errors.New("parse.LoginAs requires a master key")
+	}
+
+	if userId == "" {
+		return nil, errors.New("userId must not be empty")
+	}
+
 	if u == nil {
 		user = &User{}
 	} else if err := validateUser(u); err != nil {
@@ -68,7 +188,7 @@ func LoginFacebook(authData *FacebookAuthData, u interface{}) (Session, error) {
 	}
 
 	s := &sessionT{user: user}
-	if b, err := defaultClient.doRequest(&loginRequestT{authdata: &AuthData{Facebook: authData}}); err != nil {
+	if b, err := defaultClient.doRequest(&loginAsT{userId: userId, headers: optionHeaders(opts)}); err != nil {
 		return nil, err
 	} else if st, err := handleLoginResponse(b, s.user); err != nil {
 		return nil, err
@@ -84,7 +204,7 @@ func LoginFacebook(authData *FacebookAuthData, u interface{}) (Session, error) {
 // Optionally provide a custom User type to use in place of parse.User. If user is
 // not nil, it will be populated with the user's attributes, and will be accessible
 // by calling session.User().
-func Become(st string, u interface{}) (Session, error) {
+func Become(st string, u interface{}, opts ...RequestOption) (Session, error) {
 	var user interface{}
 
 	if u == nil {
@@ -95,26 +215,123 @@ func Become(st string, u interface{}) (Session, error) {
 		user = u
 	}
 
-	r := &loginRequestT{
-		s: &sessionT{
-			sessionToken: st,
-			user:         user,
-		},
+	s := &sessionT{sessionToken: st, user: user}
+	if err := s.refresh(opts...); err != nil {
+		return nil, err
 	}
+	return s, nil
+}
 
-	if b, err := defaultClient.doRequest(r); err != nil {
-		return nil, err
-	} else if err := handleResponse(b, r.s.user); err != nil {
+// Restore the session identified by the session token st without
+// contacting Parse. If u is not nil, it is used as the session's user as is,
+// and may be re-fetched later by calling session.Refresh().
+//
+// A session previously marshaled with encoding/json or encoding/gob may be
+// decoded into the returned session:
+//
+//	s, _ := parse.RestoreSession("", &MyUser{})
+//	err := json.Unmarshal(data, s)
+func RestoreSession(st string, u interface{}) (Session, error) {
+	var user interface{}
+
+	if u == nil {
+		user = &User{}
+	} else if err := validateUser(u); err != nil {
 		return nil, err
+	} else {
+		user = u
 	}
-	return r.s, nil
+
+	return &sessionT{sessionToken: st, user: user}, nil
 }
 
 func (s *sessionT) User() interface{} {
 	return s.user
 }
 
+func (s *sessionT) UserAs(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("dst must be a non-nil pointer")
+	}
+
+	uv := reflect.ValueOf(s.user)
+	if uv.Type().AssignableTo(rv.Elem().Type()) {
+		rv.Elem().Set(uv)
+	} else if uv.Elem().Type().AssignableTo(rv.Elem().Type()) {
+		// dst points to a user value rather than a pointer, so copy the user
+		rv.Elem().Set(uv.Elem())
+	} else {
+		return fmt.Errorf("session user is of type %s, not %s", uv.Type(), rv.Elem().Type())
+	}
+	return nil
+}
+
+func (s *sessionT) Refresh() error {
+	return s.refresh()
+}
+
+func (s *sessionT) refresh(opts ...RequestOption) error {
+	b, err := defaultClient.doRequest(&loginRequestT{s: s, headers: optionHeaders(opts)})
+	if err != nil {
+		return err
+	}
+
+	// Decode into an empty user, so that fields removed on the server don't
+	// linger from the cached copy, and the cached copy is only replaced once
+	// the response has been decoded successfully
+	rv := reflect.ValueOf(s.user).Elem()
+	nv := reflect.New(rv.Type())
+	if err := handleResponse(b, nv.Interface()); err != nil {
+		return err
+	}
+	rv.Set(nv.Elem())
+	return nil
+}
+
+// The representation of a session when marshaled
+type sessionDataT struct {
+	SessionToken string                 `json:"sessionToken"`
+	User         map[string]interface{} `json:"user"`
+}
+
+func (s *sessionT) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sessionDataT{
+		SessionToken: s.sessionToken,
+		User:         encodeObject(s.user),
+	})
+}
+
+func (s *sessionT) UnmarshalJSON(b []byte) error {
+	data := sessionDataT{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+
+	if s.user == nil {
+		s.user = &User{}
+	}
+
+	s.sessionToken = data.SessionToken
+	if data.User != nil {
+		return populateValue(s.user, data.User)
+	}
+	return nil
+}
+
+func (s *sessionT) GobEncode() ([]byte, error) {
+	return s.MarshalJSON()
+}
+
+func (s *sessionT) GobDecode(b []byte) error {
+	return s.UnmarshalJSON(b)
+}
+
 func (s *sessionT) NewQuery(v interface{}) (Query, error) {
+	if s.isInvalidated() {
+		return nil, ErrSessionInvalidated
+	}
+
 	q, err := NewQuery(v)
 	if err == nil {
 		if qt, ok := q.(*queryT); ok {
@@ -125,6 +342,10 @@ func (s *sessionT) NewQuery(v interface{}) (Query, error) {
 }
 
 func (s *sessionT) NewUpdate(v interface{}) (Update, error) {
+	if s.isInvalidated() {
+		return nil, ErrSessionInvalidated
+	}
+
 	u, err := NewUpdate(v)
 	if err == nil {
 		if ut, ok := u.(*updateT); ok {
@@ -134,16 +355,213 @@ func (s *sessionT) NewUpdate(v interface{}) (Update, error) {
 	return u, err
 }
 
-func (s *sessionT) Create(v interface{}) error {
-	return create(v, false, s)
+func (s *sessionT) Create(v interface{}, opts ...RequestOption) error {
+	return create(v, false, s, opts...)
+}
+
+func (s *sessionT) Save(v interface{}) error {
+	return save(v, false, s)
+}
+
+func (s *sessionT) Delete(v interface{}, opts ...RequestOption) error {
+	return _delete(v, false, s, opts...)
+}
+
+func (s *sessionT) CallFunction(name string, params Params, resp interface{}, opts ...RequestOption) error {
+	return callFn(name, params, resp, s, opts...)
 }
 
-func (s *sessionT) Delete(v interface{}) error {
-	return _delete(v, false, s)
+func (s *sessionT) GetConfig() (Config, error) {
+	return getConfig(s)
 }
 
-func (s *sessionT) CallFunction(name string, params Params, resp interface{}) error {
-	return callFn(name, params, resp, s)
+func (s *sessionT) NewPushNotification() PushNotification {
+	return &pushT{currentSession: s}
+}
+
+func (s *sessionT) Info() (*SessionInfo, error) {
+	info := &SessionInfo{}
+	q, err := s.NewQuery(info)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.Get("me"); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *sessionT) ListSessions() ([]*SessionInfo, error) {
+	sessions := []*SessionInfo{}
+	q, err := s.NewQuery(&sessions)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.Find(); err != nil && err != ErrNoRows {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (s *sessionT) RevokeSession(id string) error {
+	return _delete(&SessionInfo{Base: Base{Id: id}}, false, s)
+}
+
+func (s *sessionT) UpgradeToRevocableSession() error {
+	b, err := defaultClient.doRequest(&upgradeSessionT{s: s})
+	if err != nil {
+		return err
+	}
+
+	info := SessionInfo{}
+	if err := handleResponse(b, &info); err != nil {
+		return err
+	}
+	if info.SessionToken == "" {
+		return errors.New("response did not contain sessionToken")
+	}
+	s.sessionToken = info.SessionToken
+	return nil
+}
+
+func (s *sessionT) Logout() error {
+	if s.isInvalidated() {
+		return nil
+	}
+
+	_, err := defaultClient.doRequest(&logoutT{s: s})
+	if err != nil && !errors.Is(err, ErrInvalidSessionToken) {
+		return err
+	}
+
+	s.invalidate()
+	return nil
+}
+
+func (s *sessionT) invalidate() {
+	atomic.StoreInt32(&s.invalidated, 1)
+}
+
+func (s *sessionT) isInvalidated() bool {
+	return atomic.LoadInt32(&s.invalidated) == 1
+}
+
+// Retrieve the session identified by id using the master key
+func GetSession(id string) (*SessionInfo, error) {
+	info := &SessionInfo{}
+	q, _ := NewQuery(info)
+	q.UseMasterKey()
+	if err := q.Get(id); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Revoke the session identified by id using the master key
+func RevokeSession(id string) error {
+	return Delete(&SessionInfo{Base: Base{Id: id}}, true)
+}
+
+type upgradeSessionT struct {
+	s *sessionT
+}
+
+func (u *upgradeSessionT) method() string {
+	return "POST"
+}
+
+func (u *upgradeSessionT) endpoint() (string, error) {
+	p := url.URL{}
+	p.Scheme = ParseScheme
+	p.Host = parseHost
+	p.Path = path.Join(ParsePath, "upgradeToRevocableSession")
+	return p.String(), nil
+}
+
+func (u *upgradeSessionT) body() (string, error) {
+	return "{}", nil
+}
+
+func (u *upgradeSessionT) useMasterKey() bool {
+	return false
+}
+
+func (u *upgradeSessionT) session() *sessionT {
+	return u.s
+}
+
+func (u *upgradeSessionT) contentType() string {
+	return "application/json"
+}
+
+type logoutT struct {
+	s *sessionT
+}
+
+func (l *logoutT) method() string {
+	return "POST"
+}
+
+func (l *logoutT) endpoint() (string, error) {
+	u := url.URL{}
+	u.Scheme = ParseScheme
+	u.Host = parseHost
+	u.Path = path.Join(ParsePath, "logout")
+	return u.String(), nil
+}
+
+func (l *logoutT) body() (string, error) {
+	return "{}", nil
+}
+
+func (l *logoutT) useMasterKey() bool {
+	return false
+}
+
+func (l *logoutT) session() *sessionT {
+	return l.s
+}
+
+func (l *logoutT) contentType() string {
+	return "application/json"
+}
+
+type loginAsT struct {
+	userId  string
+	headers http.Header
+}
+
+func (l *loginAsT) method() string {
+	return "POST"
+}
+
+func (l *loginAsT) endpoint() (string, error) {
+	u := url.URL{}
+	u.Scheme = ParseScheme
+	u.Host = parseHost
+	u.Path = path.Join(ParsePath, "loginAs")
+	return u.String(), nil
+}
+
+func (l *loginAsT) body() (string, error) {
+	b, err := json.Marshal(map[string]string{"userId": l.userId})
+	return string(b), err
+}
+
+func (l *loginAsT) useMasterKey() bool {
+	return true
+}
+
+func (l *loginAsT) session() *sessionT {
+	return nil
+}
+
+func (l *loginAsT) contentType() string {
+	return "application/json"
+}
+
+func (l *loginAsT) requestHeaders() http.Header {
+	return l.headers
 }
 
 func (s *loginRequestT) method() string {
@@ -196,6 +614,10 @@ func (s *loginRequestT) contentType() string {
 	return "application/x-www-form-urlencoded"
 }
 
+func (s *loginRequestT) requestHeaders() http.Header {
+	return s.headers
+}
+
 func validateUser(u interface{}) error {
 	rv := reflect.ValueOf(u)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
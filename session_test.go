@@ -1,7 +1,12 @@
 package parse
 
 import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"reflect"
 	"testing"
@@ -172,3 +177,400 @@ func TestSessionOperationsSetSessionTokenHeader(t *testing.T) {
 		t.Errorf("unexpected error executing query: %v\n", err)
 	}
 }
+
+func TestSessionInfo(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get(SessionTokenHeader); h != "r:abcd" {
+			t.Errorf("request did not include session token. Got [%s]\n", h)
+		}
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/1/sessions/me":
+			fmt.Fprintf(w, `{"objectId":"s1","sessionToken":"r:abcd","user":{"__type":"Pointer","className":"_User","objectId":"u1"},"expiresAt":{"__type":"Date","iso":"2015-04-01T14:44:14.123Z"},"installationId":"i1","createdWith":{"action":"login","authProvider":"password"},"restricted":false}`)
+		case r.Method == "GET" && r.URL.Path == "/1/sessions":
+			fmt.Fprintf(w, `{"results":[{"objectId":"s1"},{"objectId":"s2"}]}`)
+		case r.Method == "DELETE" && r.URL.Path == "/1/sessions/s2":
+			fmt.Fprintf(w, `{}`)
+		default:
+			t.Errorf("unexpected request: %s %s\n", r.Method, r.URL.Path)
+		}
+	})
+	defer teardownTestServer()
+
+	s := &sessionT{user: &User{}, sessionToken: "r:abcd"}
+	info, err := s.Info()
+	if err != nil {
+		t.Fatalf("unexpected error retrieving session: %v\n", err)
+	}
+
+	expected := &SessionInfo{
+		Base:           Base{Id: "s1", Extra: map[string]interface{}{}},
+		SessionToken:   "r:abcd",
+		User:           &User{Base: Base{Id: "u1", Extra: map[string]interface{}{}}},
+		ExpiresAt:      time.Date(2015, 4, 1, 14, 44, 14, 123000000, time.UTC),
+		InstallationId: "i1",
+		CreatedWith:    map[string]interface{}{"action": "login", "authProvider": "password"},
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("unexpected session. Got:\n%+v\nexpected:\n%+v\n", info, expected)
+	}
+
+	sessions, err := s.ListSessions()
+	if err != nil {
+		t.Fatalf("unexpected error listing sessions: %v\n", err)
+	}
+	if len(sessions) != 2 || sessions[1].Id != "s2" {
+		t.Errorf("unexpected sessions: %v\n", sessions)
+	}
+
+	if err := s.RevokeSession("s2"); err != nil {
+		t.Errorf("unexpected error revoking session: %v\n", err)
+	}
+}
+
+func TestUpgradeToRevocableSession(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/1/upgradeToRevocableSession" {
+			t.Errorf("unexpected request: %s %s\n", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(SessionTokenHeader); h != "legacy" {
+			t.Errorf("request did not include legacy session token. Got [%s]\n", h)
+		}
+		fmt.Fprintf(w, `{"objectId":"s1","sessionToken":"r:new"}`)
+	})
+	defer teardownTestServer()
+
+	s := &sessionT{user: &User{}, sessionToken: "legacy"}
+	if err := s.UpgradeToRevocableSession(); err != nil {
+		t.Fatalf("unexpected error upgrading session: %v\n", err)
+	}
+	if s.sessionToken != "r:new" {
+		t.Errorf("session token was not replaced. Got %s\n", s.sessionToken)
+	}
+}
+
+func TestGetSessionUsesMasterKey(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get(MasterKeyHeader); h != "master_key" {
+			t.Errorf("request did not use master key. Got [%s]\n", h)
+		}
+		if r.URL.Path != "/1/sessions/s1" {
+			t.Errorf("unexpected path: %s\n", r.URL.Path)
+		}
+		fmt.Fprintf(w, `{"objectId":"s1","sessionToken":"r:abcd"}`)
+	})
+	defer teardownTestServer()
+
+	info, err := GetSession("s1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if info.SessionToken != "r:abcd" {
+		t.Errorf("unexpected session: %+v\n", info)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != "POST" || r.URL.Path != "/1/logout" {
+			t.Errorf("unexpected request: %s %s\n", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(SessionTokenHeader); h != "r:abcd" {
+			t.Errorf("request did not include session token. Got [%s]\n", h)
+		}
+		fmt.Fprintf(w, `{}`)
+	})
+	defer teardownTestServer()
+
+	s := &sessionT{user: &User{}, sessionToken: "r:abcd"}
+	if err := s.Logout(); err != nil {
+		t.Fatalf("unexpected error logging out: %v\n", err)
+	}
+
+	if _, err := s.NewQuery(&User{}); err != ErrSessionInvalidated {
+		t.Errorf("expected ErrSessionInvalidated from NewQuery. Got %v\n", err)
+	}
+
+	if err := s.Create(&CustomClass{}); err != ErrSessionInvalidated {
+		t.Errorf("expected ErrSessionInvalidated from Create. Got %v\n", err)
+	}
+
+	if _, err := s.Info(); err != ErrSessionInvalidated {
+		t.Errorf("expected ErrSessionInvalidated from Info. Got %v\n", err)
+	}
+
+	if _, err := s.ListSessions(); err != ErrSessionInvalidated {
+		t.Errorf("expected ErrSessionInvalidated from ListSessions. Got %v\n", err)
+	}
+
+	if requests != 1 {
+		t.Errorf("expected only the logout request to be sent. Got %d requests\n", requests)
+	}
+}
+
+func TestInvalidSessionHandler(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"code":209,"error":"invalid session token"}`)
+	})
+	defer teardownTestServer()
+
+	var handled Session
+	SetInvalidSessionHandler(func(s Session, err error) {
+		handled = s
+		if !errors.Is(err, ErrInvalidSessionToken) {
+			t.Errorf("expected invalid session token error. Got %v\n", err)
+		}
+	})
+	defer SetInvalidSessionHandler(nil)
+
+	s := &sessionT{user: &User{}, sessionToken: "r:expired"}
+	q, _ := s.NewQuery(&User{})
+	if err := q.Get("abc"); !errors.Is(err, ErrInvalidSessionToken) {
+		t.Errorf("expected invalid session token error. Got %v\n", err)
+	}
+
+	if handled != s {
+		t.Errorf("handler was not called with the session")
+	}
+
+	if err := q.Get("abc"); err != ErrSessionInvalidated {
+		t.Errorf("expected ErrSessionInvalidated. Got %v\n", err)
+	}
+}
+
+func TestLogoutDoesNotCallInvalidSessionHandler(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"code":209,"error":"invalid session token"}`)
+	})
+	defer teardownTestServer()
+
+	called := false
+	SetInvalidSessionHandler(func(s Session, err error) {
+		called = true
+	})
+	defer SetInvalidSessionHandler(nil)
+
+	s := &sessionT{user: &User{}, sessionToken: "r:expired"}
+	if err := s.Logout(); err != nil {
+		t.Fatalf("unexpected error logging out: %v\n", err)
+	}
+	if called {
+		t.Errorf("handler should not be called when logging out")
+	}
+	if !s.isInvalidated() {
+		t.Errorf("session was not invalidated")
+	}
+}
+
+func TestLoginAs(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/1/loginAs" {
+			t.Errorf("unexpected request: %s %s\n", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(MasterKeyHeader); h != "master_key" {
+			t.Errorf("request did not use master key. Got [%s]\n", h)
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != `{"userId":"u1"}` {
+			t.Errorf("unexpected body: %s\n", b)
+		}
+		fmt.Fprintf(w, `{"objectId":"u1","username":"kylemcc","sessionToken":"r:impersonated"}`)
+	})
+	defer teardownTestServer()
+
+	u := &CustomUser{}
+	s, err := LoginAs("u1", u)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if st := s.(*sessionT).sessionToken; st != "r:impersonated" {
+		t.Errorf("unexpected session token. Got %s\n", st)
+	}
+	if s.User() != u || u.Username != "kylemcc" {
+		t.Errorf("user was not populated: %+v\n", u)
+	}
+}
+
+func TestLoginAsRequiresMasterKey(t *testing.T) {
+	mk := defaultClient.masterKey
+	defaultClient.masterKey = ""
+	defer func() { defaultClient.masterKey = mk }()
+
+	if _, err := LoginAs("u1", nil); err == nil {
+		t.Errorf("expected error when no master key is configured")
+	}
+}
+
+func TestSessionMarshalJSON(t *testing.T) {
+	u := &CustomUser{
+		User: User{
+			Base: Base{
+				Id:        "u1",
+				CreatedAt: time.Date(2014, 4, 1, 14, 44, 14, 123000000, time.UTC),
+				UpdatedAt: time.Date(2015, 4, 1, 14, 44, 14, 123000000, time.UTC),
+				ACL:       NewACL().SetReadAccess("u1", true),
+				Extra: map[string]interface{}{
+					"Nickname": "kyle",
+					"Stats":    map[string]interface{}{"wins": float64(3)},
+				},
+			},
+			Username:      "kylemcc",
+			EmailVerified: true,
+		},
+		City: "Chicago",
+	}
+	s, _ := RestoreSession("r:abcd", u)
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling session: %v\n", err)
+	}
+
+	restored, _ := RestoreSession("", &CustomUser{})
+	if err := json.Unmarshal(b, restored); err != nil {
+		t.Fatalf("unexpected error unmarshaling session: %v\n", err)
+	}
+
+	if st := restored.(*sessionT).sessionToken; st != "r:abcd" {
+		t.Errorf("unexpected session token. Got %s\n", st)
+	}
+
+	ru := restored.User().(*CustomUser)
+	if ru.Id != "u1" || ru.Username != "kylemcc" || ru.City != "Chicago" || !ru.CreatedAt.Equal(u.CreatedAt) || !ru.ACL.ReadAccess("u1") {
+		t.Errorf("unexpected user. Got:\n%+v\nexpected:\n%+v\n", ru, u)
+	}
+	if !ru.EmailVerified || !ru.UpdatedAt.Equal(u.UpdatedAt) {
+		t.Errorf("read-only fields were not restored. Got:\n%+v\n", ru)
+	}
+	if !reflect.DeepEqual(ru.Extra, u.Extra) {
+		t.Errorf("Extra was not restored. Got:\n%v\nexpected:\n%v\n", ru.Extra, u.Extra)
+	}
+}
+
+func TestSessionGob(t *testing.T) {
+	s, _ := RestoreSession("r:abcd", &User{Base: Base{Id: "u1"}, Username: "kylemcc"})
+
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		t.Fatalf("unexpected error encoding session: %v\n", err)
+	}
+
+	restored, _ := RestoreSession("", nil)
+	if err := gob.NewDecoder(&buf).Decode(restored); err != nil {
+		t.Fatalf("unexpected error decoding session: %v\n", err)
+	}
+
+	if st := restored.(*sessionT).sessionToken; st != "r:abcd" {
+		t.Errorf("unexpected session token. Got %s\n", st)
+	}
+	if u := restored.User().(*User); u.Id != "u1" || u.Username != "kylemcc" {
+		t.Errorf("unexpected user: %+v\n", u)
+	}
+}
+
+func TestSessionRefresh(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/users/me" {
+			t.Errorf("unexpected request: %s %s\n", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(SessionTokenHeader); h != "r:abcd" {
+			t.Errorf("request did not include session token. Got [%s]\n", h)
+		}
+		fmt.Fprintf(w, `{"objectId":"u1","username":"renamed"}`)
+	})
+	defer teardownTestServer()
+
+	u := &CustomUser{User: User{Base: Base{Id: "u1"}, Username: "kylemcc"}, City: "Chicago"}
+	s, _ := RestoreSession("r:abcd", u)
+	if err := s.Refresh(); err != nil {
+		t.Fatalf("unexpected error refreshing session: %v\n", err)
+	}
+
+	if u.Username != "renamed" || u.City != "" {
+		t.Errorf("user was not refreshed: %+v\n", u)
+	}
+}
+
+func TestSessionRefreshKeepsUserOnError(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"objectId":"u1","createdAt":"not a date"}`)
+	})
+	defer teardownTestServer()
+
+	u := &CustomUser{User: User{Base: Base{Id: "u1"}, Username: "kylemcc"}, City: "Chicago"}
+	s, _ := RestoreSession("r:abcd", u)
+	if err := s.Refresh(); err == nil {
+		t.Fatalf("expected an error decoding the user")
+	}
+	if u.Username != "kylemcc" || u.City != "Chicago" {
+		t.Errorf("cached user should be unchanged: %+v\n", u)
+	}
+}
+
+func TestSessionUserAs(t *testing.T) {
+	u := &CustomUser{City: "Chicago"}
+	s, _ := RestoreSession("r:abcd", u)
+
+	var up *CustomUser
+	if err := s.UserAs(&up); err != nil || up != u {
+		t.Errorf("expected session user. Got %v (err: %v)\n", up, err)
+	}
+
+	var uv CustomUser
+	if err := s.UserAs(&uv); err != nil || uv.City != "Chicago" {
+		t.Errorf("expected copy of session user. Got %v (err: %v)\n", uv, err)
+	}
+
+	var wrong *User
+	if err := s.UserAs(&wrong); err == nil {
+		t.Errorf("expected error for mismatched user type")
+	}
+}
+
+func TestSessionOperationsUseSessionToken(t *testing.T) {
+	paths := []string{}
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if h := r.Header.Get(SessionTokenHeader); h != "r:abcd" {
+			t.Errorf("request to %s did not include session token. Got [%s]\n", r.URL.Path, h)
+		}
+		if h := r.Header.Get(MasterKeyHeader); h != "" {
+			t.Errorf("request to %s used master key\n", r.URL.Path)
+		}
+
+		switch r.URL.Path {
+		case "/1/config":
+			fmt.Fprintf(w, `{"params":{"welcome":"hi"}}`)
+		case "/1/push":
+			fmt.Fprintf(w, `{"result":true}`)
+		default:
+			fmt.Fprintf(w, `{"objectId":"abc"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	s, _ := RestoreSession("r:abcd", nil)
+	if c, err := s.GetConfig(); err != nil || c["welcome"] != "hi" {
+		t.Errorf("unexpected config %v (err: %v)\n", c, err)
+	}
+
+	if err := s.NewPushNotification().Channels("news").Send(); err != nil {
+		t.Errorf("unexpected error sending push: %v\n", err)
+	}
+
+	q, _ := s.NewQuery(&CustomClass{})
+	q.UseMasterKey()
+	if err := q.Get("abc"); err != nil {
+		t.Errorf("unexpected error running get: %v\n", err)
+	}
+
+	expected := []string{"/1/config", "/1/push", "/1/classes/CustomClass/abc"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("unexpected requests. Got %v\n", paths)
+	}
+}
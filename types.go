@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"path"
 	"reflect"
+	"sync"
 	"time"
 )
 
@@ -16,6 +17,7 @@ func init() {
 }
 
 var registeredTypes = map[string]reflect.Type{}
+var registeredTypesMu sync.RWMutex
 
 // An interface for custom Parse types. Contains a single method:
 //
@@ -101,10 +103,29 @@ func (a *FacebookAuthData) UnmarshalJSON(b []byte) (err error) {
 	return err
 }
 
+type AppleAuthData struct {
+	Id    string `json:"id"`
+	Token string `json:"token"`
+}
+
+type GoogleAuthData struct {
+	Id          string `json:"id"`
+	IdToken     string `json:"id_token,omitempty" parse:"id_token"`
+	AccessToken string `json:"access_token,omitempty" parse:"access_token"`
+}
+
+type GithubAuthData struct {
+	Id          string `json:"id"`
+	AccessToken string `json:"access_token" parse:"access_token"`
+}
+
 type AuthData struct {
 	Twitter   *TwitterAuthData   `json:"twitter,omitempty"`
 	Facebook  *FacebookAuthData  `json:"facebook,omitempty"`
 	Anonymous *AnonymousAuthData `json:"anonymous,omitempty"`
+	Apple     *AppleAuthData     `json:"apple,omitempty"`
+	Google    *GoogleAuthData    `json:"google,omitempty"`
+	Github    *GithubAuthData    `json:"github,omitempty"`
 }
 
 // Represents the built-in Parse "User" class. Embed this type in a custom
@@ -153,6 +174,27 @@ func (i *Installation) Endpoint() string {
 	return "installations"
 }
 
+// Represents the built-in Parse "Session" class. Sessions may be queried
+// and deleted like any other type, with the master key or by the session's
+// own user.
+type SessionInfo struct {
+	Base
+	SessionToken   string
+	User           *User
+	ExpiresAt      time.Time
+	InstallationId string
+	CreatedWith    map[string]interface{}
+	Restricted     bool
+}
+
+func (s *SessionInfo) ClassName() string {
+	return "_Session"
+}
+
+func (s *SessionInfo) Endpoint() string {
+	return "sessions"
+}
+
 type ACL interface {
 	// Returns whether public read access is enabled on this ACL
 	PublicReadAccess() bool
@@ -509,8 +551,16 @@ func getClassName(v interface{}) string {
 	if tmp, ok := v.(iClassName); ok {
 		return tmp.ClassName()
 	} else {
-		t := reflect.TypeOf(v)
-		return t.Elem().Name()
+		t := reflect.TypeOf(v).Elem()
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			// use the class name of the element type
+			te := t.Elem()
+			if te.Kind() == reflect.Ptr {
+				te = te.Elem()
+			}
+			return getClassName(reflect.New(te).Interface())
+		}
+		return t.Name()
 	}
 }
 
@@ -740,7 +790,9 @@ func (c Config) Map(key string) Config {
 	return nil
 }
 
-type configRequestT struct{}
+type configRequestT struct {
+	currentSession *sessionT
+}
 
 func (c *configRequestT) method() string {
 	return "GET"
@@ -763,7 +815,7 @@ func (c *configRequestT) useMasterKey() bool {
 }
 
 func (c *configRequestT) session() *sessionT {
-	return nil
+	return c.currentSession
 }
 
 func (c *configRequestT) contentType() string {
@@ -771,7 +823,11 @@ func (c *configRequestT) contentType() string {
 }
 
 func GetConfig() (Config, error) {
-	b, err := defaultClient.doRequest(&configRequestT{})
+	return getConfig(nil)
+}
+
+func getConfig(currentSession *sessionT) (Config, error) {
+	b, err := defaultClient.doRequest(&configRequestT{currentSession: currentSession})
 	if err != nil {
 		return nil, err
 	}
@@ -814,10 +870,20 @@ func RegisterType(t interface{}) error {
 	}
 
 	className := getClassName(t)
+	registeredTypesMu.Lock()
+	defer registeredTypesMu.Unlock()
 	registeredTypes[className] = rvi.Type()
 	return nil
 }
 
+// Returns the type registered under className by RegisterType, if any
+func registeredType(className string) (reflect.Type, bool) {
+	registeredTypesMu.RLock()
+	defer registeredTypesMu.RUnlock()
+	t, ok := registeredTypes[className]
+	return t, ok
+}
+
 // Transform the given value into the proper representation for Marshaling as part
 // of a request
 //
@@ -853,8 +919,7 @@ func encodeForRequest(v interface{}) interface{} {
 		case ACL, *ACL:
 			return v
 		case AuthData, *AuthData:
-			b, _ := json.Marshal(v)
-			return string(b)
+			return v
 		default:
 			var cname string
 
@@ -873,6 +938,14 @@ func encodeForRequest(v interface{}) interface{} {
 					ClassName: cname,
 				}
 			}
+
+			// Nested objects are stored with the same field names used
+			// when querying and updating them: the parse tag name if the
+			// field has one, and otherwise the name encoding/json would
+			// use. Types that marshal themselves are left to encoding/json.
+			if pt := reflect.PtrTo(rt); !pt.Implements(jsonMarshalerType) && !pt.Implements(textMarshalerType) {
+				return codecFor(rt).encodeNested(rvi)
+			}
 		}
 	} else if rt.Kind() == reflect.Slice {
 		vals := make([]interface{}, 0, rv.Len())
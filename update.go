@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"path"
 	"reflect"
+	"strings"
+	"time"
 )
 
 type updateTypeT int
@@ -106,10 +109,35 @@ type Update interface {
 	// Use the Master Key for this update request
 	UseMasterKey() Update
 
+	// Only apply the update if the object matches the constraints of q, e.g.:
+	//
+	//	q, _ := parse.NewQuery(&Game{})
+	//	up.If(q.EqualTo("status", "open"))
+	//
+	// Execute returns ErrPreconditionFailed if the object doesn't match. Note
+	// that Parse reports a missing object in the same way.
+	If(q Query) Update
+
+	// Only apply the update if the object hasn't been modified since t,
+	// typically the UpdatedAt time of the object when it was read. Execute
+	// returns ErrPreconditionFailed if it has been.
+	IfUnmodifiedSince(t time.Time) Update
+
+	// Use the Maintenance Key for this update request. See
+	// parse.SetMaintenanceKey
+	UseMaintenanceKey() Update
+
+	// Set a header to be sent with this update request, replacing any value
+	// set by default, e.g. parse.InstallationIdHeader
+	SetHeader(k, v string) Update
+
 	// Execute this update. This method also updates the proper fields
 	// on the provided value with their repective new values
 	Execute() error
 
+	// Describe the request Execute would send, without sending it
+	Describe() (*RequestDescription, error)
+
 	requestT
 }
 
@@ -118,8 +146,18 @@ type updateT struct {
 	values             map[string]updateOpT
 	shouldUseMasterKey bool
 	currentSession     *sessionT
+	headers            http.Header
+	key                keyT
+
+	// Conditions the object must meet for the update to be applied
+	where map[string]interface{}
 }
 
+// Returned by Update.Execute when the object no longer meets the conditions
+// set with Update.If or Update.IfUnmodifiedSince. Callers may re-read the
+// object and retry.
+var ErrPreconditionFailed = errors.New("update precondition failed")
+
 // Create a new update request for the Parse object represented by v.
 //
 // Note: v should be a pointer to a struct whose name represents a Parse class,
@@ -171,6 +209,10 @@ func (u *updateT) SetACL(a ACL) Update {
 	return u
 }
 
+func (u *updateT) Describe() (*RequestDescription, error) {
+	return describe(u)
+}
+
 func (u *updateT) Execute() (err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -182,83 +224,354 @@ func (u *updateT) Execute() (err error) {
 		}
 	}()
 
-	rv := reflect.ValueOf(u.inst)
-	rvi := reflect.Indirect(rv)
-	fieldMap := getFieldNameMap(rv)
+	// Only apply the update locally once it has succeeded
+	b, err := defaultClient.doRequest(u)
+	if err != nil {
+		if u.where != nil && errors.Is(err, ErrObjectNotFound) {
+			return ErrPreconditionFailed
+		}
+		return err
+	}
 
+	rvi := reflect.Indirect(reflect.ValueOf(u.inst))
 	for k, v := range u.values {
-		var fname string
-		if fn, ok := fieldMap[k]; ok {
-			fname = fn
+		path := strings.Split(k, ".")
+		if isWriteOnlyKey(path[0]) {
+			continue
+		}
+
+		// Parse merges auth data by provider rather than replacing it, and
+		// unlinks providers whose auth data is nil
+		if m, ok := v.Value.(map[string]interface{}); ok && k == "authData" && v.UpdateType == opSet {
+			for p, ad := range m {
+				op := updateOpT{UpdateType: opSet, Value: ad}
+				if ad == nil {
+					op = updateOpT{UpdateType: opDelete}
+				}
+				if err := applyUpdatePath(rvi, []string{k, p}, op); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if err := applyUpdatePath(rvi, path, v); err != nil {
+			return err
+		}
+	}
+
+	// Reconcile with the values returned by Parse, such as the results of
+	// Increment and array operations
+	data := map[string]interface{}{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	for k, v := range data {
+		if strings.Contains(k, ".") {
+			if err := applyUpdatePath(rvi, strings.Split(k, "."), updateOpT{UpdateType: opSet, Value: v}); err != nil {
+				return err
+			}
+			delete(data, k)
+		}
+	}
+	return populateValue(u.inst, data)
+}
+
+// Reports whether k is a key that Parse accepts in updates but never
+// returns in objects - a user's password, or an internal key beginning with
+// an underscore. Such keys are not applied to the updated value.
+func isWriteOnlyKey(k string) bool {
+	return k == "password" || strings.HasPrefix(k, "_")
+}
+
+// Applies op to the value found by following path from v, which must be
+// addressable. Each element of path names a field of a struct, or a key of
+// a map. Fields that don't exist are stored in the struct's Extra field if it
+// has one, and are ignored otherwise.
+func applyUpdatePath(v reflect.Value, path []string, op updateOpT) error {
+	if len(path) == 0 {
+		return applyUpdateOp(v, op)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			if op.UpdateType == opDelete {
+				return nil
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return applyUpdatePath(v.Elem(), path, op)
+	case reflect.Struct:
+		codec := codecFor(v.Type())
+		if fp := codec.field(path[0]); fp != nil {
+			return applyUpdatePath(v.FieldByIndex(fp.index), path[1:], op)
+		} else if codec.extra != nil {
+			p := append([]string{firstToUpper(path[0])}, path[1:]...)
+			return applyUpdatePath(v.FieldByIndex(codec.extra), p, op)
+		}
+		return nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		if len(path) == 1 && op.UpdateType == opDelete {
+			if !v.IsNil() {
+				v.SetMapIndex(key, reflect.Value{})
+			}
+			return nil
+		}
+
+		cur := reflect.Value{}
+		if !v.IsNil() {
+			cur = v.MapIndex(key)
+		}
+		if !cur.IsValid() && op.UpdateType == opDelete {
+			return nil
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		// Map elements aren't addressable, so update a copy and store it
+		ev := reflect.New(v.Type().Elem()).Elem()
+		if cur.IsValid() {
+			ev.Set(cur)
+		}
+		if err := applyUpdatePath(ev, path[1:], op); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, ev)
+		return nil
+	case reflect.Interface:
+		if v.IsNil() {
+			if op.UpdateType == opDelete {
+				return nil
+			}
+			v.Set(reflect.ValueOf(map[string]interface{}{}))
+		}
+
+		ev := reflect.New(v.Elem().Type()).Elem()
+		ev.Set(v.Elem())
+		if err := applyUpdatePath(ev, path, op); err != nil {
+			return err
+		}
+		v.Set(ev)
+		return nil
+	}
+
+	return fmt.Errorf("can not update %s - expected struct or map, got %s", strings.Join(path, "."), v.Kind())
+}
+
+// Applies op to the addressable value fv
+func applyUpdateOp(fv reflect.Value, op updateOpT) error {
+	switch op.UpdateType {
+	case opSet:
+		// The new value replaces the old one rather than being merged into it,
+		// and a pointer field is given a new value rather than modifying the
+		// one it points to
+		fv.Set(reflect.Zero(fv.Type()))
+		if fv.Kind() == reflect.Ptr && op.Value != nil {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+
+		var tmp reflect.Value
+		if fv.Kind() == reflect.Ptr && op.Value != nil {
+			tmp = fv
 		} else {
-			fname = k
+			tmp = fv.Addr()
 		}
+		return populateValue(tmp.Interface(), op.Value)
+	case opIncr:
+		return incrementValue(fv, op.Value)
+	case opDelete:
+		fv.Set(reflect.Zero(fv.Type()))
+	case opAdd, opAddUnique, opRemove:
+		return applyArrayOp(fv, op)
+	}
+	return nil
+}
 
-		fname = firstToUpper(fname)
+func incrementValue(fv reflect.Value, amount interface{}) error {
+	fvi := reflect.Indirect(fv)
+	dvi := reflect.Indirect(reflect.ValueOf(amount))
+	if !dvi.IsValid() {
+		return nil
+	}
 
-		dv := reflect.ValueOf(v.Value)
-		dvi := reflect.Indirect(dv)
+	switch fvi.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if dvi.Type().ConvertibleTo(fvi.Type()) {
+			current := fvi.Int()
+			current += dvi.Convert(fvi.Type()).Int()
+			fvi.Set(reflect.ValueOf(current).Convert(fvi.Type()))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if dvi.Type().ConvertibleTo(fvi.Type()) {
+			current := fvi.Uint()
+			current += dvi.Convert(fvi.Type()).Uint()
+			fvi.Set(reflect.ValueOf(current).Convert(fvi.Type()))
+		}
+	case reflect.Float32, reflect.Float64:
+		if dvi.Type().ConvertibleTo(fvi.Type()) {
+			current := fvi.Float()
+			current += dvi.Convert(fvi.Type()).Float()
+			fvi.Set(reflect.ValueOf(current).Convert(fvi.Type()))
+		}
+	case reflect.Interface:
+		// Untyped values, e.g. in Extra, hold numbers as float64
+		if !dvi.Type().ConvertibleTo(reflect.TypeOf(float64(0))) {
+			return nil
+		}
+		var current float64
+		if !fvi.IsNil() {
+			if f, ok := fvi.Interface().(float64); ok {
+				current = f
+			} else {
+				return nil
+			}
+		}
+		current += dvi.Convert(reflect.TypeOf(float64(0))).Float()
+		fvi.Set(reflect.ValueOf(current))
+	}
+	return nil
+}
 
-		if fv := rvi.FieldByName(fname); fv.IsValid() {
-			fvi := reflect.Indirect(fv)
+// Applies the Add, AddUnique, or Remove op to the slice fv
+func applyArrayOp(fv reflect.Value, op updateOpT) error {
+	vs, _ := op.Value.([]interface{})
 
-			switch v.UpdateType {
-			case opSet:
-				if fv.Kind() == reflect.Ptr && fv.IsNil() && v.Value != nil {
-					fv.Set(reflect.New(fv.Type().Elem()))
-				}
+	target := fv
+	if fv.Kind() == reflect.Interface {
+		// Untyped values hold arrays as []interface{}
+		cur, ok := fv.Interface().([]interface{})
+		if !ok && !fv.IsNil() {
+			return nil
+		}
+		target = reflect.New(reflect.TypeOf(cur)).Elem()
+		target.Set(reflect.ValueOf(cur))
+	} else if fv.Kind() != reflect.Slice {
+		return fmt.Errorf("can not apply %s to %s - expected slice", op.UpdateType, fv.Type())
+	}
 
-				var tmp reflect.Value
-				if fv.Kind() == reflect.Ptr {
-					if v.Value == nil {
-						tmp = fv.Addr()
-					} else {
-						tmp = fv
-					}
-				} else {
-					tmp = fv.Addr()
-				}
-				if err := populateValue(tmp.Interface(), v.Value); err != nil {
-					return err
-				}
-			case opIncr:
-				switch fvi.Kind() {
-				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-					if dvi.Type().ConvertibleTo(fvi.Type()) {
-						current := fvi.Int()
-						amount := dvi.Convert(fvi.Type()).Int()
-						current += amount
-						fvi.Set(reflect.ValueOf(current).Convert(fvi.Type()))
-					}
-				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-					if dvi.Type().ConvertibleTo(fvi.Type()) {
-						current := fvi.Uint()
-						amount := dvi.Convert(fvi.Type()).Uint()
-						current += amount
-						fvi.Set(reflect.ValueOf(current).Convert(fvi.Type()))
-					}
-				case reflect.Float32, reflect.Float64:
-					if dvi.Type().ConvertibleTo(fvi.Type()) {
-						current := fvi.Float()
-						amount := dvi.Convert(fvi.Type()).Float()
-						current += amount
-						fvi.Set(reflect.ValueOf(current).Convert(fvi.Type()))
-					}
-				}
-			case opDelete:
-				fv.Set(reflect.Zero(fv.Type()))
+	elems := make([]reflect.Value, 0, len(vs))
+	for _, v := range vs {
+		et := target.Type().Elem()
+		if v != nil && reflect.TypeOf(v).AssignableTo(et) {
+			elems = append(elems, reflect.ValueOf(v))
+			continue
+		}
+
+		ev := reflect.New(et)
+		if err := populateValue(ev.Interface(), v); err != nil {
+			return err
+		}
+		elems = append(elems, ev.Elem())
+	}
+
+	contains := func(s reflect.Value, e reflect.Value) bool {
+		for i := 0; i < s.Len(); i++ {
+			if sameElement(s.Index(i), e) {
+				return true
 			}
 		}
+		return false
 	}
-	if b, err := defaultClient.doRequest(u); err != nil {
-		return err
-	} else {
-		return handleResponse(b, u.inst)
+
+	result := reflect.MakeSlice(target.Type(), 0, target.Len()+len(elems))
+	switch op.UpdateType {
+	case opAdd:
+		result = reflect.AppendSlice(result, target)
+		result = reflect.Append(result, elems...)
+	case opAddUnique:
+		result = reflect.AppendSlice(result, target)
+		for _, e := range elems {
+			if !contains(result, e) {
+				result = reflect.Append(result, e)
+			}
+		}
+	case opRemove:
+		rm := reflect.Append(reflect.MakeSlice(target.Type(), 0, len(elems)), elems...)
+		for i := 0; i < target.Len(); i++ {
+			if !contains(rm, target.Index(i)) {
+				result = reflect.Append(result, target.Index(i))
+			}
+		}
+	}
+
+	fv.Set(result)
+	return nil
+}
+
+// Returns whether a and b represent the same array element. Parse objects
+// are the same if their Ids match.
+func sameElement(a, b reflect.Value) bool {
+	ai, bi := reflect.Indirect(a), reflect.Indirect(b)
+	if ai.Kind() == reflect.Interface {
+		ai = reflect.Indirect(ai.Elem())
 	}
+	if bi.Kind() == reflect.Interface {
+		bi = reflect.Indirect(bi.Elem())
+	}
+	if !ai.IsValid() || !bi.IsValid() {
+		return ai.IsValid() == bi.IsValid()
+	}
+
+	if ai.Kind() == reflect.Struct && bi.Kind() == reflect.Struct {
+		aid, bid := ai.FieldByName("Id"), bi.FieldByName("Id")
+		if aid.IsValid() && bid.IsValid() && aid.Kind() == reflect.String && aid.String() != "" {
+			return aid.String() == bid.String()
+		}
+	}
+	return reflect.DeepEqual(ai.Interface(), bi.Interface())
+}
+
+func (u *updateT) SetHeader(k, v string) Update {
+	if u.headers == nil {
+		u.headers = http.Header{}
+	}
+	u.headers.Set(k, v)
+	return u
+}
+
+func (u *updateT) requestHeaders() http.Header {
+	return u.headers
+}
+
+func (u *updateT) If(q Query) Update {
+	if u.where == nil {
+		u.where = map[string]interface{}{}
+	}
+	for k, v := range copyConstraints(q.(*queryT).where) {
+		u.where[k] = v
+	}
+	return u
+}
+
+func (u *updateT) IfUnmodifiedSince(t time.Time) Update {
+	if u.where == nil {
+		u.where = map[string]interface{}{}
+	}
+	u.where["updatedAt"] = map[string]interface{}{"$lte": Date(t)}
+	return u
+}
+
+func (u *updateT) UseMaintenanceKey() Update {
+	u.shouldUseMasterKey = true
+	u.key = keyMaintenance
+	return u
+}
+
+func (u *updateT) privilegedKey() keyT {
+	return u.key
 }
 
 func (u *updateT) UseMasterKey() Update {
 	u.shouldUseMasterKey = true
+	u.key = keyMaster
 	return u
 }
 
@@ -286,11 +599,25 @@ func (u *updateT) endpoint() (string, error) {
 	_url.Host = parseHost
 	_url.Path = p
 
+	if u.where != nil {
+		w, err := json.Marshal(wireConstraints(structType(u.inst), u.where))
+		if err != nil {
+			return "", err
+		}
+		_url.RawQuery = url.Values{"where": []string{string(w)}}.Encode()
+	}
+
 	return _url.String(), nil
 }
 
 func (u *updateT) body() (string, error) {
-	b, err := json.Marshal(u.values)
+	t := structType(u.inst)
+	values := make(map[string]updateOpT, len(u.values))
+	for k, v := range u.values {
+		values[wireKey(t, k)] = v
+	}
+
+	b, err := json.Marshal(values)
 	if err != nil {
 		return "", err
 	}
@@ -311,12 +638,5 @@ func (u *updateT) contentType() string {
 }
 
 func LinkFacebookAccount(u *User, a *FacebookAuthData) error {
-	if u.Id == "" {
-		return errors.New("user Id field must not be empty")
-	}
-
-	up, _ := NewUpdate(u)
-	up.Set("authData", AuthData{Facebook: a})
-	up.UseMasterKey()
-	return up.Execute()
+	return LinkWith(u, AuthProviderFacebook, a)
 }
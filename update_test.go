@@ -2,6 +2,7 @@ package parse
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -273,3 +274,189 @@ func TestUpdateUseMasterKey(t *testing.T) {
 		t.Errorf("Unexpected error executing update: %v\n", err)
 	}
 }
+
+func TestUpdateIf(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		expected := `{"status":"open","updatedAt":{"$lte":{"__type":"Date","iso":"2015-04-01T14:44:14.123Z"}}}`
+		if where := r.URL.Query().Get("where"); where != expected {
+			t.Errorf("unexpected where. Got:\n%s\nexpected:\n%s\n", where, expected)
+		}
+		fmt.Fprintf(w, `{"updatedAt":"2015-04-02T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	g := &TrackedGame{Base: Base{Id: "g1"}}
+	q, _ := NewQuery(&TrackedGame{})
+	q.EqualTo("status", "open")
+
+	up, _ := NewUpdate(g)
+	up.Set("score", 5).If(q).IfUnmodifiedSince(time.Date(2015, 4, 1, 14, 44, 14, 123000000, time.UTC))
+	if err := up.Execute(); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+	if g.Score != 5 {
+		t.Errorf("update was not applied locally: %+v\n", g)
+	}
+}
+
+func TestUpdatePreconditionFailed(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"code":101,"error":"Object not found."}`)
+	})
+	defer teardownTestServer()
+
+	g := &TrackedGame{Base: Base{Id: "g1"}, Score: 1}
+	up, _ := NewUpdate(g)
+	up.Set("score", 5).IfUnmodifiedSince(time.Now())
+	if err := up.Execute(); err != ErrPreconditionFailed {
+		t.Errorf("expected ErrPreconditionFailed. Got %v\n", err)
+	}
+	if g.Score != 1 {
+		t.Errorf("failed update should not be applied locally: %+v\n", g)
+	}
+
+	// Without a precondition, the error is returned as is
+	up, _ = NewUpdate(g)
+	up.Set("score", 5)
+	if err := up.Execute(); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected ErrObjectNotFound. Got %v\n", err)
+	}
+}
+
+type ScoreDetails struct {
+	Views int
+	Label string `parse:"label"`
+}
+
+type Scoreboard struct {
+	Base
+	Tags    []string
+	Players []*User
+	Stats   map[string]int
+	Details *ScoreDetails
+}
+
+func TestUpdateAppliesAllOpsLocally(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"updatedAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	s := &Scoreboard{
+		Base:    Base{Id: "s1", Extra: map[string]interface{}{"Counters": map[string]interface{}{"hits": float64(1)}}},
+		Tags:    []string{"a", "b"},
+		Players: []*User{{Base: Base{Id: "u1"}}, {Base: Base{Id: "u2"}}},
+		Stats:   map[string]int{"views": 1},
+	}
+
+	up, _ := NewUpdate(s)
+	up.Add("tags", "c", "a")
+	up.Remove("players", &User{Base: Base{Id: "u1"}})
+	up.Increment("stats.views", 2)
+	up.Set("stats.likes", 5)
+	up.Set("details.label", "weekly")
+	up.Increment("details.views", 3)
+	up.Increment("counters.hits", 1)
+	if err := up.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	if !reflect.DeepEqual(s.Tags, []string{"a", "b", "c", "a"}) {
+		t.Errorf("unexpected tags: %v\n", s.Tags)
+	}
+	if len(s.Players) != 1 || s.Players[0].Id != "u2" {
+		t.Errorf("unexpected players: %v\n", s.Players)
+	}
+	if !reflect.DeepEqual(s.Stats, map[string]int{"views": 3, "likes": 5}) {
+		t.Errorf("unexpected stats: %v\n", s.Stats)
+	}
+	if s.Details == nil || *s.Details != (ScoreDetails{Views: 3, Label: "weekly"}) {
+		t.Errorf("unexpected details: %+v\n", s.Details)
+	}
+	if hits := s.Extra["Counters"].(map[string]interface{})["hits"]; hits != float64(2) {
+		t.Errorf("unexpected hits: %v\n", hits)
+	}
+
+	up, _ = NewUpdate(s)
+	up.AddUnique("tags", "a", "d")
+	up.Delete("stats.likes")
+	if err := up.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	if !reflect.DeepEqual(s.Tags, []string{"a", "b", "c", "a", "d"}) {
+		t.Errorf("unexpected tags: %v\n", s.Tags)
+	}
+	if !reflect.DeepEqual(s.Stats, map[string]int{"views": 3}) {
+		t.Errorf("unexpected stats: %v\n", s.Stats)
+	}
+}
+
+func TestUpdateDoesNotMirrorWriteOnlyKeys(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"updatedAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	u := &User{Base: Base{Id: "u1"}}
+	up, _ := NewUpdate(u)
+	up.Set("password", "hunter2").Set("_internal", 1).Set("nickname", "kyle")
+	if err := up.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	expected := map[string]interface{}{"Nickname": "kyle"}
+	if !reflect.DeepEqual(u.Extra, expected) {
+		t.Errorf("unexpected Extra. Got %v expected %v\n", u.Extra, expected)
+	}
+}
+
+func TestUpdateSetReplacesLocalValue(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"updatedAt":"2015-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	d := &ScoreDetails{Views: 3}
+	s := &Scoreboard{Base: Base{Id: "s1"}, Stats: map[string]int{"views": 1}, Details: d}
+	up, _ := NewUpdate(s)
+	up.Set("stats", map[string]int{"likes": 2}).Set("details", &ScoreDetails{Label: "weekly"})
+	if err := up.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	if !reflect.DeepEqual(s.Stats, map[string]int{"likes": 2}) {
+		t.Errorf("stats were merged rather than replaced: %v\n", s.Stats)
+	}
+	if s.Details == nil || *s.Details != (ScoreDetails{Label: "weekly"}) {
+		t.Errorf("unexpected details: %+v\n", s.Details)
+	}
+	if *d != (ScoreDetails{Views: 3}) {
+		t.Errorf("previous details should not be modified: %+v\n", d)
+	}
+}
+
+func TestUpdateReconcilesWithResponse(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"updatedAt":"2015-04-01T14:44:14.123Z","tags":["x","y"],"stats.views":10}`)
+	})
+	defer teardownTestServer()
+
+	s := &Scoreboard{Base: Base{Id: "s1"}, Tags: []string{"x"}}
+	up, _ := NewUpdate(s)
+	up.Add("tags", "z").Increment("stats.views", 1)
+	if err := up.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	if !reflect.DeepEqual(s.Tags, []string{"x", "y"}) {
+		t.Errorf("tags were not reconciled with the response: %v\n", s.Tags)
+	}
+	if s.Stats["views"] != 10 {
+		t.Errorf("stats were not reconciled with the response: %v\n", s.Stats)
+	}
+	if _, ok := s.Extra["Stats.views"]; ok {
+		t.Errorf("nested key should not be stored in Extra: %v\n", s.Extra)
+	}
+}
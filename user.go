@@ -0,0 +1,129 @@
+package parse
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// Send a password reset email to the user with the given email address
+func RequestPasswordReset(email string, opts ...RequestOption) error {
+	_, err := defaultClient.doRequest(&userEmailRequestT{action: "requestPasswordReset", email: email, headers: optionHeaders(opts)})
+	return err
+}
+
+// Resend the email verification email to the user with the given email
+// address
+func RequestEmailVerification(email string, opts ...RequestOption) error {
+	_, err := defaultClient.doRequest(&userEmailRequestT{action: "verificationEmailRequest", email: email, headers: optionHeaders(opts)})
+	return err
+}
+
+// Verify that the provided username and password are valid, without
+// creating a session.
+//
+// Optionally provide a custom User type to use in place of parse.User. If u is not
+// nil, it will be populated with the user's attributes. Returns the populated user.
+func VerifyPassword(username, password string, u interface{}, opts ...RequestOption) (interface{}, error) {
+	var user interface{}
+
+	if u == nil {
+		user = &User{}
+	} else if err := validateUser(u); err != nil {
+		return nil, err
+	} else {
+		user = u
+	}
+
+	r := &verifyPasswordT{username: username, password: password, headers: optionHeaders(opts)}
+	if b, err := defaultClient.doRequest(r); err != nil {
+		return nil, err
+	} else if err := handleResponse(b, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+type userEmailRequestT struct {
+	action  string
+	email   string
+	headers http.Header
+}
+
+func (r *userEmailRequestT) method() string {
+	return "POST"
+}
+
+func (r *userEmailRequestT) endpoint() (string, error) {
+	u := url.URL{}
+	u.Scheme = ParseScheme
+	u.Host = parseHost
+	u.Path = path.Join(ParsePath, r.action)
+	return u.String(), nil
+}
+
+func (r *userEmailRequestT) body() (string, error) {
+	b, err := json.Marshal(map[string]string{"email": r.email})
+	return string(b), err
+}
+
+func (r *userEmailRequestT) useMasterKey() bool {
+	return false
+}
+
+func (r *userEmailRequestT) session() *sessionT {
+	return nil
+}
+
+func (r *userEmailRequestT) contentType() string {
+	return "application/json"
+}
+
+func (r *userEmailRequestT) requestHeaders() http.Header {
+	return r.headers
+}
+
+type verifyPasswordT struct {
+	username string
+	password string
+	headers  http.Header
+}
+
+func (r *verifyPasswordT) method() string {
+	return "GET"
+}
+
+func (r *verifyPasswordT) endpoint() (string, error) {
+	u := url.URL{}
+	u.Scheme = ParseScheme
+	u.Host = parseHost
+	u.Path = path.Join(ParsePath, "verifyPassword")
+
+	v := url.Values{}
+	v["username"] = []string{r.username}
+	v["password"] = []string{r.password}
+	u.RawQuery = v.Encode()
+
+	return u.String(), nil
+}
+
+func (r *verifyPasswordT) body() (string, error) {
+	return "", nil
+}
+
+func (r *verifyPasswordT) useMasterKey() bool {
+	return false
+}
+
+func (r *verifyPasswordT) session() *sessionT {
+	return nil
+}
+
+func (r *verifyPasswordT) contentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+func (r *verifyPasswordT) requestHeaders() http.Header {
+	return r.headers
+}
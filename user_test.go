@@ -0,0 +1,92 @@
+package parse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestPasswordReset(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		fn   func(string, ...RequestOption) error
+	}{
+		{"/1/requestPasswordReset", RequestPasswordReset},
+		{"/1/verificationEmailRequest", RequestEmailVerification},
+	} {
+		setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" || r.URL.Path != tc.path {
+				t.Errorf("unexpected request: %s %s\n", r.Method, r.URL.Path)
+			}
+
+			b, _ := ioutil.ReadAll(r.Body)
+			body := map[string]string{}
+			json.Unmarshal(b, &body)
+			if body["email"] != "kylemcc@gmail.com" {
+				t.Errorf("request did not include email. Got %s\n", b)
+			}
+			fmt.Fprintf(w, `{}`)
+		})
+
+		if err := tc.fn("kylemcc@gmail.com"); err != nil {
+			t.Errorf("unexpected error: %v\n", err)
+		}
+		teardownTestServer()
+	}
+}
+
+func TestRequestPasswordResetError(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"code":205,"error":"no user found with email kylemcc@gmail.com"}`)
+	})
+	defer teardownTestServer()
+
+	err := RequestPasswordReset("kylemcc@gmail.com")
+	var perr ParseError
+	if !errors.As(err, &perr) || perr.Code() != 205 {
+		t.Errorf("expected ParseError with code 205. Got %v\n", err)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/1/verifyPassword" {
+			t.Errorf("unexpected request: %s %s\n", r.Method, r.URL.Path)
+		}
+		if r.URL.Query().Get("username") != "kylemcc" || r.URL.Query().Get("password") != "secret" {
+			t.Errorf("request did not include credentials: %s\n", r.URL.RawQuery)
+		}
+		fmt.Fprintf(w, `{"objectId":"u1","username":"kylemcc","phone":"555-555-5555"}`)
+	})
+	defer teardownTestServer()
+
+	u := &CustomUser{}
+	v, err := VerifyPassword("kylemcc", "secret", u)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if v != u || u.Id != "u1" || u.Username != "kylemcc" || u.Phone != "555-555-5555" {
+		t.Errorf("user was not populated: %+v\n", u)
+	}
+}
+
+func TestVerifyPasswordInvalid(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"code":101,"error":"Invalid username/password."}`)
+	})
+	defer teardownTestServer()
+
+	_, err := VerifyPassword("kylemcc", "wrong", nil)
+	if !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected ErrObjectNotFound. Got %v\n", err)
+	}
+	if strings.Contains(err.Error(), "wrong") {
+		t.Errorf("error contained password: %v\n", err)
+	}
+}